@@ -0,0 +1,216 @@
+// Package anagram groups words that consist of the same multiset of letters.
+package anagram
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// KeyMode selects the word a group is keyed by in the output.
+type KeyMode string
+
+// Supported group keys.
+const (
+	KeyFirst     KeyMode = "first"     // the first word of the group seen in the input
+	KeySmallest  KeyMode = "smallest"  // the alphabetically smallest word of the group
+	KeySignature KeyMode = "signature" // the sorted letters of the group
+)
+
+// ParseKeyMode converts a key mode name into a KeyMode.
+func ParseKeyMode(s string) (KeyMode, error) {
+	switch k := KeyMode(s); k {
+	case KeyFirst, KeySmallest, KeySignature:
+		return k, nil
+	}
+	return "", fmt.Errorf("unknown group key: %s", s)
+}
+
+// Options controls how words are normalised, grouped and filtered.
+type Options struct {
+	FoldYo    bool      // treat "ё" as "е"
+	Signature Signature // grouping key, SortedSignature if nil
+	Workers   int       // number of goroutines used by Sharded
+
+	MinSize      int     // smallest group to keep, 2 if zero
+	MaxSize      int     // largest group to keep, 0 for no limit
+	PreserveCase bool    // output words as they were written, not lower cased
+	Dedup        bool    // keep repeated words of a group once
+	Key          KeyMode // group key, KeyFirst if empty
+}
+
+func (o Options) signature() Signature {
+	if o.Signature == nil {
+		return SortedSignature
+	}
+	return o.Signature
+}
+
+// forms returns the normalised word used for grouping and the word put into the output.
+func (o Options) forms(w string) (string, string) {
+	word := Normalize(w, o)
+	if !o.PreserveCase || word == "" {
+		return word, word
+	}
+	return word, norm.NFC.String(strings.TrimSpace(w))
+}
+
+// compare orders output words by their normalised form, so that the case
+// kept with PreserveCase does not decide the smallest word or the order of
+// groups. Words of the same form are ordered as written.
+func (o Options) compare(a, b string) int {
+	if o.PreserveCase {
+		if c := strings.Compare(Normalize(a, o), Normalize(b, o)); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// keep reports whether a group of n words passes the size filter.
+func (o Options) keep(n int) bool {
+	minSize := o.MinSize
+	if minSize == 0 {
+		minSize = 2
+	}
+	return n >= minSize && (o.MaxSize == 0 || n <= o.MaxSize)
+}
+
+// Group is a set of words that are anagrams of each other.
+type Group struct {
+	Key   string   `json:"key"`
+	Words []string `json:"words"`
+}
+
+// Grouper accumulates words and returns them grouped by letters.
+type Grouper interface {
+	Add(w string)
+	ReadWords(r io.Reader) error
+	Groups() []Group
+}
+
+type group struct {
+	firstW string
+	first  int // sequence number of firstW in the input
+	words  []string
+}
+
+// Finder accumulates words and groups them by their letters.
+type Finder struct {
+	opts   Options
+	sig    Signature
+	seq    int
+	groups map[string]*group
+}
+
+// New creates an empty Finder with the given options.
+func New(opts Options) *Finder {
+	return &Finder{
+		opts:   opts,
+		sig:    opts.signature(),
+		groups: make(map[string]*group),
+	}
+}
+
+// Find groups the given words and returns anagram groups of at least two words.
+func Find(data []string, opts Options) []Group {
+	f := New(opts)
+	for _, w := range data {
+		f.Add(w)
+	}
+	return f.Groups()
+}
+
+// Add normalises a word and puts it into its group. Empty words are ignored.
+func (f *Finder) Add(w string) {
+	word, display := f.opts.forms(w)
+	if word == "" {
+		return
+	}
+
+	addWord(f.groups, f.sig(word), display, f.seq)
+	f.seq++
+}
+
+// ReadWords adds every line of r as a word. Lines are read one by one,
+// so the dictionary never has to be held in memory as a whole.
+func (f *Finder) ReadWords(r io.Reader) error {
+	return readWords(f, r)
+}
+
+// Groups returns the groups that pass the size filter of the options, by
+// default those with at least two words. Words inside a group are sorted,
+// groups are sorted by key, so the result is deterministic.
+func (f *Finder) Groups() []Group {
+	res := make([]Group, 0, len(f.groups))
+	res = appendGroups(res, f.groups, f.opts)
+	sortGroups(res, f.opts)
+	return res
+}
+
+// Normalize trims the word, converts it to NFC and lower case and,
+// if requested, folds "ё" into "е".
+func Normalize(w string, opts Options) string {
+	word := norm.NFC.String(strings.TrimSpace(w))
+	word = strings.ToLower(word)
+	if opts.FoldYo {
+		word = strings.ReplaceAll(word, "ё", "е")
+	}
+	return word
+}
+
+func readWords(dst interface{ Add(string) }, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		dst.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func addWord(groups map[string]*group, key, word string, seq int) {
+	g, ok := groups[key]
+	if !ok {
+		g = &group{firstW: word, first: seq}
+		groups[key] = g
+	} else if seq < g.first {
+		g.firstW, g.first = word, seq
+	}
+
+	g.words = append(g.words, word)
+}
+
+func appendGroups(res []Group, groups map[string]*group, opts Options) []Group {
+	for sig, g := range groups {
+		words := make([]string, len(g.words))
+		copy(words, g.words)
+		slices.SortFunc(words, opts.compare)
+
+		if opts.Dedup {
+			words = slices.Compact(words)
+		}
+		if !opts.keep(len(words)) {
+			continue
+		}
+
+		key := g.firstW
+		switch opts.Key {
+		case KeySmallest:
+			key = words[0]
+		case KeySignature:
+			key = sig
+		}
+
+		res = append(res, Group{Key: key, Words: words})
+	}
+	return res
+}
+
+func sortGroups(res []Group, opts Options) {
+	slices.SortFunc(res, func(a, b Group) int {
+		return opts.compare(a.Key, b.Key)
+	})
+}
@@ -0,0 +1,300 @@
+package anagram
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		opts  Options
+		want  []Group
+	}{
+		{
+			name:  "Basic groups",
+			input: []string{"пятак", "пятка", "тяпка", "листок", "слиток", "столик", "стол"},
+			want: []Group{
+				{Key: "листок", Words: []string{"листок", "слиток", "столик"}},
+				{Key: "пятак", Words: []string{"пятак", "пятка", "тяпка"}},
+			},
+		},
+		{
+			name:  "Case is ignored",
+			input: []string{"Пятак", "ПЯТКА"},
+			want:  []Group{{Key: "пятак", Words: []string{"пятак", "пятка"}}},
+		},
+		{
+			name:  "Single words are dropped",
+			input: []string{"стол", "стул"},
+			want:  []Group{},
+		},
+		{
+			name:  "NFC normalisation",
+			input: []string{"йод", "дйо"},
+			want:  []Group{{Key: "йод", Words: []string{"дйо", "йод"}}},
+		},
+		{
+			name:  "Yo is kept by default",
+			input: []string{"ёлка", "елка"},
+			want:  []Group{},
+		},
+		{
+			name:  "Yo folding",
+			input: []string{"ёлка", "калее", "елка"},
+			opts:  Options{FoldYo: true},
+			want:  []Group{{Key: "елка", Words: []string{"елка", "елка"}}},
+		},
+		{
+			name:  "Min size 1 keeps single words",
+			input: []string{"кот", "ток", "дом"},
+			opts:  Options{MinSize: 1},
+			want: []Group{
+				{Key: "дом", Words: []string{"дом"}},
+				{Key: "кот", Words: []string{"кот", "ток"}},
+			},
+		},
+		{
+			name:  "Max size",
+			input: []string{"кот", "ток", "кто", "пятак", "пятка"},
+			opts:  Options{MaxSize: 2},
+			want:  []Group{{Key: "пятак", Words: []string{"пятак", "пятка"}}},
+		},
+		{
+			name:  "Preserve case",
+			input: []string{"Пятак", "ПЯТКА", "тяпка"},
+			opts:  Options{PreserveCase: true},
+			want:  []Group{{Key: "Пятак", Words: []string{"Пятак", "ПЯТКА", "тяпка"}}},
+		},
+		{
+			name:  "Smallest key ignores case",
+			input: []string{"тяпка", "Пятка", "пятак", "тор", "Рот"},
+			opts:  Options{PreserveCase: true, Key: KeySmallest},
+			want: []Group{
+				{Key: "пятак", Words: []string{"пятак", "Пятка", "тяпка"}},
+				{Key: "Рот", Words: []string{"Рот", "тор"}},
+			},
+		},
+		{
+			name:  "Repeated words are kept by default",
+			input: []string{"кот", "кот"},
+			want:  []Group{{Key: "кот", Words: []string{"кот", "кот"}}},
+		},
+		{
+			name:  "Dedup counts distinct words",
+			input: []string{"кот", "Кот", "ток", "дом", "дом"},
+			opts:  Options{Dedup: true},
+			want:  []Group{{Key: "кот", Words: []string{"кот", "ток"}}},
+		},
+		{
+			name:  "Smallest key",
+			input: []string{"тяпка", "пятак", "пятка"},
+			opts:  Options{Key: KeySmallest},
+			want:  []Group{{Key: "пятак", Words: []string{"пятак", "пятка", "тяпка"}}},
+		},
+		{
+			name:  "Signature key",
+			input: []string{"тяпка", "пятак"},
+			opts:  Options{Key: KeySignature},
+			want:  []Group{{Key: "акптя", Words: []string{"пятак", "тяпка"}}},
+		},
+		{
+			name:  "Empty lines are ignored",
+			input: []string{"", "  ", "кот", "ток"},
+			want:  []Group{{Key: "кот", Words: []string{"кот", "ток"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Find(tt.input, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinder_ReadWords(t *testing.T) {
+	f := New(Options{})
+	if err := f.ReadWords(strings.NewReader("кот\nток\nкто\nдом\n")); err != nil {
+		t.Fatalf("ReadWords() error = %v", err)
+	}
+
+	want := []Group{{Key: "кот", Words: []string{"кот", "кто", "ток"}}}
+	if got := f.Groups(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Groups() = %v, want %v", got, want)
+	}
+}
+
+func TestSharded(t *testing.T) {
+	words := randomWords(20000, 1)
+
+	for _, workers := range []int{1, 3, 8} {
+		for name, sig := range map[string]Signature{"sort": SortedSignature, "count": CountSignature} {
+			t.Run(fmt.Sprintf("%s/%d", name, workers), func(t *testing.T) {
+				want := Find(words, Options{})
+
+				s := NewSharded(Options{Signature: sig, Workers: workers})
+				for _, w := range words {
+					s.Add(w)
+				}
+				if got := s.Groups(); !reflect.DeepEqual(got, want) {
+					t.Errorf("Sharded groups differ from Finder: got %d groups, want %d", len(got), len(want))
+				}
+			})
+		}
+	}
+}
+
+func TestSharded_Options(t *testing.T) {
+	words := append(randomWords(5000, 4), "Пятак", "пятак", "ПЯТКА")
+	opts := Options{MinSize: 1, MaxSize: 3, PreserveCase: true, Dedup: true, Key: KeySmallest, Workers: 4}
+
+	want := Find(words, opts)
+	s := NewSharded(opts)
+	for _, w := range words {
+		s.Add(w)
+	}
+	if got := s.Groups(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Sharded groups differ from Finder: got %d groups, want %d", len(got), len(want))
+	}
+}
+
+func TestSharded_Empty(t *testing.T) {
+	s := NewSharded(Options{Workers: 2})
+	if got := s.Groups(); len(got) != 0 || got == nil {
+		t.Errorf("Groups() = %#v, want empty slice", got)
+	}
+}
+
+func TestCountSignature(t *testing.T) {
+	for _, w := range []string{"", "пятак", "abcabc", "ёлка", "日本語", "a日a", strings.Repeat("я", 300)} {
+		if got, want := CountSignature(w), SortedSignature(w); got != want {
+			t.Errorf("CountSignature(%q) = %q, want %q", w, got, want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	groups := []Group{
+		{Key: "кот", Words: []string{"кот", "ток"}},
+		{Key: "листок", Words: []string{"листок", "слиток"}},
+	}
+
+	tests := []struct {
+		name   string
+		format Format
+		groups []Group
+		want   string
+	}{
+		{
+			name:   "Text",
+			format: FormatText,
+			groups: groups,
+			want:   "-\"кот\": [кот ток]\n-\"листок\": [листок слиток]\n",
+		},
+		{
+			name:   "JSON",
+			format: FormatJSON,
+			groups: groups,
+			want:   `[{"key":"кот","words":["кот","ток"]},{"key":"листок","words":["листок","слиток"]}]` + "\n",
+		},
+		{
+			name:   "JSON empty",
+			format: FormatJSON,
+			want:   "[]\n",
+		},
+		{
+			name:   "CSV",
+			format: FormatCSV,
+			groups: groups,
+			want:   "key,word\nкот,кот\nкот,ток\nлисток,листок\nлисток,слиток\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.groups, tt.format); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Write() = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat(xml) expected error")
+	}
+	if f, err := ParseFormat("csv"); err != nil || f != FormatCSV {
+		t.Errorf("ParseFormat(csv) = %v, %v", f, err)
+	}
+}
+
+// randomWords generates n short Cyrillic words, so that a fair share of
+// them are anagrams of each other.
+func randomWords(n int, seed int64) []string {
+	rnd := rand.New(rand.NewSource(seed))
+	letters := []rune("абвгдеклмнопрст")
+
+	words := make([]string, n)
+	for i := range words {
+		w := make([]rune, 3+rnd.Intn(5))
+		for j := range w {
+			w[j] = letters[rnd.Intn(len(letters))]
+		}
+		words[i] = string(w)
+	}
+	return words
+}
+
+func BenchmarkSignature(b *testing.B) {
+	words := randomWords(1000, 2)
+
+	b.Run("sort", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			getSorted(words[i%len(words)])
+		}
+	})
+	b.Run("count", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CountSignature(words[i%len(words)])
+		}
+	})
+}
+
+func BenchmarkGroup(b *testing.B) {
+	words := randomWords(200000, 3)
+
+	benchmarks := []struct {
+		name string
+		new  func() Grouper
+	}{
+		{"Finder/sort", func() Grouper { return New(Options{}) }},
+		{"Finder/count", func() Grouper { return New(Options{Signature: CountSignature}) }},
+		{"Sharded4/sort", func() Grouper { return NewSharded(Options{Workers: 4}) }},
+		{"Sharded4/count", func() Grouper { return NewSharded(Options{Workers: 4, Signature: CountSignature}) }},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				g := bm.new()
+				for _, w := range words {
+					g.Add(w)
+				}
+				g.Groups()
+			}
+		})
+	}
+}
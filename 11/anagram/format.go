@@ -0,0 +1,107 @@
+package anagram
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an output format for anagram groups.
+type Format string
+
+// Supported output formats.
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+)
+
+// ParseFormat converts a format name into a Format.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatText, FormatJSON, FormatCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format: %s", s)
+}
+
+// Write prints groups to w in the given format.
+func Write(w io.Writer, groups []Group, format Format) error {
+	switch format {
+	case FormatText:
+		return writeText(w, groups)
+	case FormatJSON:
+		return writeJSON(w, groups)
+	case FormatCSV:
+		return writeCSV(w, groups)
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+func writeText(w io.Writer, groups []Group) error {
+	bw := bufio.NewWriter(w)
+	for _, g := range groups {
+		if _, err := fmt.Fprintf(bw, "-%q: %v\n", g.Key, g.Words); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func writeJSON(w io.Writer, groups []Group) error {
+	if groups == nil {
+		groups = []Group{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(groups)
+}
+
+// writeCSV prints one "key,word" row per word, so every row has the same shape.
+func writeCSV(w io.Writer, groups []Group) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"key", "word"}); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for _, word := range g.Words {
+			if err := cw.Write([]string{g.Key, word}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WritePhrases prints phrase anagrams to w in the given format. Text output
+// has one phrase per line, CSV output has one word per column.
+func WritePhrases(w io.Writer, phrases [][]string, format Format) error {
+	switch format {
+	case FormatText:
+		bw := bufio.NewWriter(w)
+		for _, p := range phrases {
+			if _, err := fmt.Fprintln(bw, strings.Join(p, " ")); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	case FormatJSON:
+		if phrases == nil {
+			phrases = [][]string{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(phrases)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(phrases); err != nil {
+			return err
+		}
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown output format: %s", format)
+}
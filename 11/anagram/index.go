@@ -0,0 +1,213 @@
+package anagram
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// indexVersion is bumped whenever the on-disk index layout changes.
+const indexVersion = 1
+
+// Wildcards are the query runes that stand for any single letter.
+const Wildcards = "?."
+
+// ErrIndexVersion is returned when a saved index has an unsupported version.
+var ErrIndexVersion = errors.New("unsupported index version")
+
+// Index maps word signatures to dictionary words, so anagram queries can be
+// answered repeatedly without grouping the dictionary again.
+type Index struct {
+	opts  Options
+	words map[string][]string // signature -> sorted unique words
+	byLen map[int][]string    // rune length -> signatures
+}
+
+// indexFile is the gob encoded form of an Index.
+type indexFile struct {
+	Version int
+	FoldYo  bool
+	Words   map[string][]string
+}
+
+// NewIndex creates an empty index. Only opts.FoldYo matters for an index,
+// queries are normalised the same way as the dictionary.
+func NewIndex(opts Options) *Index {
+	return &Index{
+		opts:  Options{FoldYo: opts.FoldYo},
+		words: make(map[string][]string),
+		byLen: make(map[int][]string),
+	}
+}
+
+// BuildIndex reads a dictionary with one word per line into a new index.
+func BuildIndex(r io.Reader, opts Options) (*Index, error) {
+	ix := NewIndex(opts)
+	if err := readWords(ix, r); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// LoadIndex reads an index previously written by Save.
+func LoadIndex(r io.Reader) (*Index, error) {
+	var f indexFile
+	if err := gob.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("decode index: %w", err)
+	}
+	if f.Version != indexVersion {
+		return nil, fmt.Errorf("%w: %d", ErrIndexVersion, f.Version)
+	}
+
+	ix := NewIndex(Options{FoldYo: f.FoldYo})
+	for sig, words := range f.Words {
+		ix.words[sig] = words
+		ix.addLen(sig)
+	}
+	return ix, nil
+}
+
+// Save writes the index to w in a compact binary form.
+func (ix *Index) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(indexFile{
+		Version: indexVersion,
+		FoldYo:  ix.opts.FoldYo,
+		Words:   ix.words,
+	})
+}
+
+// Add normalises a word and adds it to the index. Repeated words are stored once.
+func (ix *Index) Add(w string) {
+	word := Normalize(w, ix.opts)
+	if word == "" {
+		return
+	}
+
+	sig := SortedSignature(word)
+	words, ok := ix.words[sig]
+	if !ok {
+		ix.addLen(sig)
+	}
+
+	i := sort.SearchStrings(words, word)
+	if i < len(words) && words[i] == word {
+		return
+	}
+	words = append(words, "")
+	copy(words[i+1:], words[i:])
+	words[i] = word
+	ix.words[sig] = words
+}
+
+// ReadWords adds every line of r as a word.
+func (ix *Index) ReadWords(r io.Reader) error {
+	return readWords(ix, r)
+}
+
+// Len returns the number of distinct words in the index.
+func (ix *Index) Len() int {
+	n := 0
+	for _, words := range ix.words {
+		n += len(words)
+	}
+	return n
+}
+
+// Anagrams returns the dictionary words made of exactly the letters of the
+// query, including the query itself if it is in the dictionary. Every
+// wildcard rune in the query matches any single letter.
+func (ix *Index) Anagrams(query string) []string {
+	letters, wild := ix.parseQuery(query)
+	if len(letters) == 0 && wild == 0 {
+		return []string{}
+	}
+
+	if wild == 0 {
+		return append([]string{}, ix.words[string(letters)]...)
+	}
+
+	var res []string
+	for _, sig := range ix.byLen[len(letters)+wild] {
+		if missing(sig, letters) <= wild {
+			res = append(res, ix.words[sig]...)
+		}
+	}
+	return sortWords(res)
+}
+
+// SubAnagrams returns the dictionary words that can be made of a subset of
+// the letters of the query. Every wildcard rune matches any single letter.
+// Longer words go first. A positive limit caps the number of results.
+func (ix *Index) SubAnagrams(query string, limit int) []string {
+	letters, wild := ix.parseQuery(query)
+
+	var res []string
+	for n := len(letters) + wild; n > 0; n-- {
+		var words []string
+		for _, sig := range ix.byLen[n] {
+			if missing(sig, letters) <= wild {
+				words = append(words, ix.words[sig]...)
+			}
+		}
+		res = append(res, sortWords(words)...)
+
+		if limit > 0 && len(res) >= limit {
+			return res[:limit]
+		}
+	}
+	if res == nil {
+		res = []string{}
+	}
+	return res
+}
+
+func (ix *Index) addLen(sig string) {
+	n := len([]rune(sig))
+	ix.byLen[n] = append(ix.byLen[n], sig)
+}
+
+// parseQuery normalises the query and splits it into sorted letters and
+// the number of wildcards.
+func (ix *Index) parseQuery(query string) ([]rune, int) {
+	var letters []rune
+	wild := 0
+	for _, r := range Normalize(query, ix.opts) {
+		if strings.ContainsRune(Wildcards, r) {
+			wild++
+			continue
+		}
+		letters = append(letters, r)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i] < letters[j]
+	})
+	return letters, wild
+}
+
+// missing counts the runes of sig that are not covered by letters.
+// Both are sorted.
+func missing(sig string, letters []rune) int {
+	n, j := 0, 0
+	for _, r := range sig {
+		for j < len(letters) && letters[j] < r {
+			j++
+		}
+		if j < len(letters) && letters[j] == r {
+			j++
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+func sortWords(words []string) []string {
+	if words == nil {
+		return []string{}
+	}
+	sort.Strings(words)
+	return words
+}
@@ -0,0 +1,105 @@
+package anagram
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDict = "пятак\nпятка\nтяпка\nпятак\nпят\nтяп\nка\nлисток\nслиток\nстолик\nстол\nЁлка\n"
+
+func TestIndex_Anagrams(t *testing.T) {
+	ix, err := BuildIndex(strings.NewReader(testDict), Options{})
+	if err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "Exact", query: "Катяп", want: []string{"пятак", "пятка", "тяпка"}},
+		{name: "Not found", query: "кот", want: []string{}},
+		{name: "Single wildcard", query: "стол?к", want: []string{"листок", "слиток", "столик"}},
+		{name: "Dot wildcard", query: "ст.л", want: []string{"стол"}},
+		{name: "Only wildcards", query: "??", want: []string{"ка"}},
+		{name: "Empty", query: "", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ix.Anagrams(tt.query); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Anagrams(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_SubAnagrams(t *testing.T) {
+	ix, err := BuildIndex(strings.NewReader(testDict), Options{})
+	if err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{name: "Subset", query: "пятак", want: []string{"пятак", "пятка", "тяпка", "пят", "тяп", "ка"}},
+		{name: "Limit", query: "пятак", limit: 4, want: []string{"пятак", "пятка", "тяпка", "пят"}},
+		{name: "Wildcard", query: "пя?", want: []string{"пят", "тяп"}},
+		{name: "Nothing", query: "ж", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ix.SubAnagrams(tt.query, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SubAnagrams(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_SaveLoad(t *testing.T) {
+	ix, err := BuildIndex(strings.NewReader(testDict), Options{FoldYo: true})
+	if err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ix.Save(&buf); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadIndex(&buf)
+	if err != nil {
+		t.Fatalf("LoadIndex() error = %v", err)
+	}
+
+	if loaded.Len() != ix.Len() {
+		t.Errorf("Len() = %d, want %d", loaded.Len(), ix.Len())
+	}
+	if got, want := loaded.Anagrams("ёклА"), []string{"елка"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Anagrams() after load = %v, want %v", got, want)
+	}
+	if got, want := loaded.SubAnagrams("стол", 0), ix.SubAnagrams("стол", 0); !reflect.DeepEqual(got, want) {
+		t.Errorf("SubAnagrams() after load = %v, want %v", got, want)
+	}
+}
+
+func TestLoadIndex_Version(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(indexFile{Version: indexVersion + 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadIndex(&buf); !errors.Is(err, ErrIndexVersion) {
+		t.Errorf("LoadIndex() error = %v, want %v", err, ErrIndexVersion)
+	}
+}
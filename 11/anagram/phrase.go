@@ -0,0 +1,208 @@
+package anagram
+
+import (
+	"context"
+	"sort"
+	"unicode"
+)
+
+// checkEvery is the number of search steps between context checks.
+const checkEvery = 1024
+
+// PhraseOptions limits the phrase anagram search.
+type PhraseOptions struct {
+	MaxWords   int // maximum number of words in a phrase, 0 for no limit
+	MaxResults int // maximum number of phrases returned, 0 for no limit
+}
+
+// candidate is a dictionary signature that fits into the phrase letters.
+type candidate struct {
+	sig    string
+	counts []int // letter counts over the phrase alphabet
+	size   int
+}
+
+type phraseSearch struct {
+	ctx   context.Context
+	ix    *Index
+	opts  PhraseOptions
+	cands []candidate
+	steps int
+	chain []int
+	res   [][]string
+}
+
+// Phrases finds all multi-word anagrams of phrase, e.g. "dormitory" gives
+// "dirty room". The phrase is normalised like dictionary words, everything
+// but letters and digits is ignored. Every phrase is returned once as a list
+// of words, regardless of word order. If ctx is cancelled, the phrases found
+// so far are returned together with the context error.
+func (ix *Index) Phrases(ctx context.Context, phrase string, opts PhraseOptions) ([][]string, error) {
+	alphabet, counts := phraseLetters(Normalize(phrase, ix.opts))
+
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if total == 0 {
+		return [][]string{}, nil
+	}
+
+	s := &phraseSearch{
+		ctx:   ctx,
+		ix:    ix,
+		opts:  opts,
+		cands: ix.candidates(alphabet, counts),
+		res:   [][]string{},
+	}
+
+	err := s.search(0, counts, total)
+	return s.res, err
+}
+
+// phraseLetters returns the sorted distinct letters of the phrase and their counts.
+func phraseLetters(phrase string) ([]rune, []int) {
+	seen := make(map[rune]int)
+	for _, r := range phrase {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			seen[r]++
+		}
+	}
+
+	alphabet := make([]rune, 0, len(seen))
+	for r := range seen {
+		alphabet = append(alphabet, r)
+	}
+	sort.Slice(alphabet, func(i, j int) bool {
+		return alphabet[i] < alphabet[j]
+	})
+
+	counts := make([]int, len(alphabet))
+	for i, r := range alphabet {
+		counts[i] = seen[r]
+	}
+	return alphabet, counts
+}
+
+// candidates returns the signatures made of the phrase letters, longest first.
+func (ix *Index) candidates(alphabet []rune, counts []int) []candidate {
+	pos := make(map[rune]int, len(alphabet))
+	for i, r := range alphabet {
+		pos[r] = i
+	}
+
+	var res []candidate
+	for sig := range ix.words {
+		c := candidate{sig: sig, counts: make([]int, len(alphabet))}
+		fits := true
+		for _, r := range sig {
+			i, ok := pos[r]
+			if !ok || c.counts[i] == counts[i] {
+				fits = false
+				break
+			}
+			c.counts[i]++
+			c.size++
+		}
+		if fits {
+			res = append(res, c)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].size != res[j].size {
+			return res[i].size > res[j].size
+		}
+		return res[i].sig < res[j].sig
+	})
+	return res
+}
+
+// search picks candidates starting from start, so that every combination of
+// signatures is visited once. It stops early when enough phrases are found
+// or the context is cancelled.
+func (s *phraseSearch) search(start int, counts []int, left int) error {
+	if left == 0 {
+		s.emit()
+		return nil
+	}
+	if s.opts.MaxWords > 0 && len(s.chain) == s.opts.MaxWords {
+		return nil
+	}
+
+	for i := start; i < len(s.cands); i++ {
+		if s.done() {
+			return nil
+		}
+
+		s.steps++
+		if s.steps%checkEvery == 0 {
+			if err := s.ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		c := &s.cands[i]
+		if c.size > left || !fits(c.counts, counts) {
+			continue
+		}
+
+		for j, n := range c.counts {
+			counts[j] -= n
+		}
+		s.chain = append(s.chain, i)
+
+		err := s.search(i, counts, left-c.size)
+
+		s.chain = s.chain[:len(s.chain)-1]
+		for j, n := range c.counts {
+			counts[j] += n
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *phraseSearch) done() bool {
+	return s.opts.MaxResults > 0 && len(s.res) >= s.opts.MaxResults
+}
+
+// emit expands the current chain of signatures into phrases. A signature
+// used several times gets its words in non-decreasing order to avoid
+// repeating the same phrase.
+func (s *phraseSearch) emit() {
+	words := make([]string, len(s.chain))
+
+	var expand func(k, from int)
+	expand = func(k, from int) {
+		if s.done() {
+			return
+		}
+		if k == len(s.chain) {
+			s.res = append(s.res, append([]string(nil), words...))
+			return
+		}
+
+		list := s.ix.words[s.cands[s.chain[k]].sig]
+		if k == 0 || s.chain[k] != s.chain[k-1] {
+			from = 0
+		}
+		for i := from; i < len(list); i++ {
+			words[k] = list[i]
+			expand(k+1, i)
+		}
+	}
+	expand(0, 0)
+}
+
+func fits(need, have []int) bool {
+	for i, n := range need {
+		if n > have[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package anagram
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndex_Phrases(t *testing.T) {
+	ix, err := BuildIndex(strings.NewReader("dirty\nroom\ndormitory\nroomy\ndirt\nmy\nor\ntidy\nmoor\nrot\ndim\nry\n"), Options{})
+	if err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		phrase string
+		opts   PhraseOptions
+		want   [][]string
+	}{
+		{
+			name:   "Dormitory",
+			phrase: "Dormitory",
+			opts:   PhraseOptions{MaxWords: 2},
+			want:   [][]string{{"dormitory"}, {"dirty", "moor"}, {"dirty", "room"}, {"roomy", "dirt"}},
+		},
+		{
+			name:   "Punctuation and spaces are ignored",
+			phrase: "Dirty, room!",
+			opts:   PhraseOptions{MaxWords: 1},
+			want:   [][]string{{"dormitory"}},
+		},
+		{
+			name:   "Max results",
+			phrase: "dormitory",
+			opts:   PhraseOptions{MaxWords: 2, MaxResults: 2},
+			want:   [][]string{{"dormitory"}, {"dirty", "moor"}},
+		},
+		{
+			name:   "Repeated signature is not permuted",
+			phrase: "moor room",
+			opts:   PhraseOptions{MaxWords: 2},
+			want:   [][]string{{"moor", "moor"}, {"moor", "room"}, {"room", "room"}},
+		},
+		{
+			name:   "No letters",
+			phrase: "!!",
+			want:   [][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ix.Phrases(context.Background(), tt.phrase, tt.opts)
+			if err != nil {
+				t.Fatalf("Phrases() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Phrases(%q) = %v, want %v", tt.phrase, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndex_PhrasesCancel(t *testing.T) {
+	ix := NewIndex(Options{})
+	for _, w := range []string{"a", "b", "ab", "aa", "bb", "aab", "abb"} {
+		ix.Add(w)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ix.Phrases(ctx, strings.Repeat("ab", 40), PhraseOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Phrases() error = %v, want %v", err, context.Canceled)
+	}
+}
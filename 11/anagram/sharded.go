@@ -0,0 +1,163 @@
+package anagram
+
+import (
+	"io"
+	"runtime"
+	"sync"
+)
+
+// batchSize is the number of words sent through a channel at once.
+const batchSize = 1024
+
+type entry struct {
+	key  string
+	word string
+	seq  int
+}
+
+// Sharded groups words concurrently. Words are normalised and signed by
+// Options.Workers goroutines and then routed by signature hash to one of as
+// many shards. Each shard map is owned by a single goroutine, so no locks
+// are taken on the hot path. The result is identical to Finder's.
+type Sharded struct {
+	opts Options
+	sig  Signature
+
+	batch []entry
+	seq   int
+
+	in     chan []entry
+	shards []chan []entry
+	groups []map[string]*group
+
+	signers sync.WaitGroup
+	owners  sync.WaitGroup
+}
+
+// NewSharded creates a Sharded grouper and starts its goroutines. If
+// opts.Workers is not positive, runtime.GOMAXPROCS(0) is used.
+// Groups must be called exactly once to release them.
+func NewSharded(opts Options) *Sharded {
+	n := opts.Workers
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+
+	s := &Sharded{
+		opts:   opts,
+		sig:    opts.signature(),
+		batch:  make([]entry, 0, batchSize),
+		in:     make(chan []entry, n),
+		shards: make([]chan []entry, n),
+		groups: make([]map[string]*group, n),
+	}
+
+	for i := range n {
+		s.shards[i] = make(chan []entry, n)
+		s.groups[i] = make(map[string]*group)
+
+		s.owners.Add(1)
+		go s.own(i)
+	}
+
+	for range n {
+		s.signers.Add(1)
+		go s.sign()
+	}
+
+	return s
+}
+
+// Add queues a word for grouping. Add must not be called after Groups.
+func (s *Sharded) Add(w string) {
+	s.batch = append(s.batch, entry{word: w, seq: s.seq})
+	s.seq++
+
+	if len(s.batch) == batchSize {
+		s.in <- s.batch
+		s.batch = make([]entry, 0, batchSize)
+	}
+}
+
+// ReadWords adds every line of r as a word.
+func (s *Sharded) ReadWords(r io.Reader) error {
+	return readWords(s, r)
+}
+
+// Groups waits for all queued words to be grouped and returns the groups
+// in the same order as Finder.Groups.
+func (s *Sharded) Groups() []Group {
+	if len(s.batch) > 0 {
+		s.in <- s.batch
+		s.batch = nil
+	}
+	close(s.in)
+	s.signers.Wait()
+
+	for _, ch := range s.shards {
+		close(ch)
+	}
+	s.owners.Wait()
+
+	var res []Group
+	for _, groups := range s.groups {
+		res = appendGroups(res, groups, s.opts)
+	}
+	if res == nil {
+		res = []Group{}
+	}
+	sortGroups(res, s.opts)
+
+	return res
+}
+
+// sign normalises and signs batches of words and routes them to shards.
+func (s *Sharded) sign() {
+	defer s.signers.Done()
+
+	n := len(s.shards)
+	out := make([][]entry, n)
+
+	for batch := range s.in {
+		for _, e := range batch {
+			word, display := s.opts.forms(e.word)
+			if word == "" {
+				continue
+			}
+
+			key := s.sig(word)
+			i := shardOf(key, n)
+			out[i] = append(out[i], entry{key: key, word: display, seq: e.seq})
+		}
+
+		for i, b := range out {
+			if len(b) == 0 {
+				continue
+			}
+			s.shards[i] <- b
+			out[i] = nil
+		}
+	}
+}
+
+// own adds routed words to the map of shard i.
+func (s *Sharded) own(i int) {
+	defer s.owners.Done()
+
+	groups := s.groups[i]
+	for batch := range s.shards[i] {
+		for _, e := range batch {
+			addWord(groups, e.key, e.word, e.seq)
+		}
+	}
+}
+
+// shardOf hashes key with FNV-1a without allocating.
+func shardOf(key string, n int) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % uint32(n))
+}
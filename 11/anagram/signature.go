@@ -0,0 +1,64 @@
+package anagram
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Signature maps a normalised word to a key that is equal for all of its anagrams.
+type Signature func(word string) string
+
+// countTableSize covers Latin and Cyrillic letters, which is where
+// CountSignature can use a counting table instead of sorting.
+const countTableSize = 0x500
+
+// ParseSignature returns the signature function with the given name.
+func ParseSignature(name string) (Signature, error) {
+	switch name {
+	case "sort":
+		return SortedSignature, nil
+	case "count":
+		return CountSignature, nil
+	}
+	return nil, fmt.Errorf("unknown signature: %s", name)
+}
+
+// SortedSignature sorts the runes of the word.
+func SortedSignature(word string) string {
+	return getSorted(word)
+}
+
+// CountSignature counts the runes of the word and writes each of them as
+// many times as it occurs. The result is the same as SortedSignature, but for
+// Latin and Cyrillic words no comparison sort is done. Words with other
+// runes fall back to sorting.
+func CountSignature(word string) string {
+	var counts [countTableSize]uint8
+
+	lo, hi := rune(countTableSize), rune(-1)
+	for _, r := range word {
+		if r >= countTableSize || counts[r] == 255 {
+			return getSorted(word)
+		}
+		counts[r]++
+		lo, hi = min(lo, r), max(hi, r)
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(word))
+	for r := lo; r <= hi; r++ {
+		for n := counts[r]; n > 0; n-- {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func getSorted(word string) string {
+	runes := []rune(word)
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+	return string(runes)
+}
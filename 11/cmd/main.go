@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"anagram/anagram"
+	"anagram/internal/config"
+	"anagram/internal/server"
+)
+
+// wordReader is anything the dictionary can be read into.
+type wordReader interface {
+	ReadWords(r io.Reader) error
+}
+
+func main() {
+	cfg := config.InitConfig()
+
+	var err error
+	if cfg.UseIndex() {
+		err = runIndex(cfg)
+	} else {
+		err = runGroups(cfg)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func runGroups(cfg *config.Config) error {
+	f := newGrouper(cfg)
+	if err := readInput(f, cfg.Files); err != nil {
+		return err
+	}
+
+	return anagram.Write(os.Stdout, f.Groups(), cfg.Format)
+}
+
+func runIndex(cfg *config.Config) error {
+	ix, err := loadIndex(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.SaveIndex != "" {
+		if err := saveIndex(ix, cfg.SaveIndex); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Phrase != "" {
+		return findPhrases(ix, cfg)
+	}
+	if cfg.Serve != "" {
+		return serve(ix, cfg.Serve)
+	}
+	return nil
+}
+
+// findPhrases prints multi-word anagrams of cfg.Phrase. An interrupt stops
+// the search and prints what was found so far.
+func findPhrases(ix *anagram.Index, cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	phrases, err := ix.Phrases(ctx, cfg.Phrase, cfg.PhraseOptions())
+	if werr := anagram.WritePhrases(os.Stdout, phrases, cfg.Format); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// newGrouper returns a sequential Finder for a single worker and
+// a Sharded grouper otherwise.
+func newGrouper(cfg *config.Config) anagram.Grouper {
+	if cfg.Workers == 1 {
+		return anagram.New(cfg.Options())
+	}
+	return anagram.NewSharded(cfg.Options())
+}
+
+func loadIndex(cfg *config.Config) (*anagram.Index, error) {
+	if cfg.IndexFile == "" {
+		ix := anagram.NewIndex(cfg.Options())
+		if err := readInput(ix, cfg.Files); err != nil {
+			return nil, err
+		}
+		return ix, nil
+	}
+
+	file, err := os.Open(cfg.IndexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return anagram.LoadIndex(file)
+}
+
+func saveIndex(ix *anagram.Index, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := ix.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func serve(ix *anagram.Index, addr string) error {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	h := server.NewHandler(ix, logger)
+
+	httpServer := &http.Server{
+		Addr:         addr,
+		Handler:      h.Routes(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		logger.Info("http server started", slog.String("addr", addr), slog.Int("words", ix.Len()))
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+func readInput(dst wordReader, files []string) error {
+	if len(files) == 0 {
+		return dst.ReadWords(os.Stdin)
+	}
+
+	for _, path := range files {
+		if err := readFile(dst, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(dst wordReader, path string) error {
+	if path == "-" {
+		return dst.ReadWords(os.Stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return dst.ReadWords(file)
+}
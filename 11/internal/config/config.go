@@ -0,0 +1,137 @@
+// Package config parses command line arguments and validates configuration.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"anagram/anagram"
+)
+
+// Config holds the configuration for the anagram finder.
+type Config struct {
+	Format    anagram.Format    // -format
+	FoldYo    bool              // -yo
+	Signature anagram.Signature // -sig
+	Workers   int               // -workers
+
+	MinSize      int             // -min
+	MaxSize      int             // -max
+	PreserveCase bool            // -preserve-case
+	Dedup        bool            // -dedup
+	Key          anagram.KeyMode // -key
+
+	IndexFile string // -index, load a saved index instead of reading words
+	SaveIndex string // -save-index, build an index and save it
+	Serve     string // -serve, address of the lookup server
+
+	Phrase     string // -phrase, find multi-word anagrams of the phrase
+	MaxWords   int    // -max-words
+	MaxResults int    // -max-results
+
+	Files []string
+}
+
+// InitConfig initializes and returns a Config with command-line flags parsed.
+func InitConfig() *Config {
+	cfg := Config{}
+	format := flag.String("format", string(anagram.FormatText), "output format: text, json or csv")
+	sig := flag.String("sig", "sort", "word signature: sort or count")
+	flag.BoolVar(&cfg.FoldYo, "yo", false, "treat ё as е")
+	flag.IntVar(&cfg.Workers, "workers", 1, "number of grouping goroutines (0 for GOMAXPROCS)")
+	flag.IntVar(&cfg.MinSize, "min", 2, "minimum group size")
+	flag.IntVar(&cfg.MaxSize, "max", 0, "maximum group size (0 for no limit)")
+	flag.BoolVar(&cfg.PreserveCase, "preserve-case", false, "output words in their original case")
+	flag.BoolVar(&cfg.Dedup, "dedup", false, "drop repeated words within a group")
+	key := flag.String("key", string(anagram.KeyFirst), "group key: first, smallest or signature")
+	flag.StringVar(&cfg.IndexFile, "index", "", "load a saved index from `file`")
+	flag.StringVar(&cfg.SaveIndex, "save-index", "", "build an index and save it to `file`")
+	flag.StringVar(&cfg.Serve, "serve", "", "serve lookups over HTTP on `addr`")
+	flag.StringVar(&cfg.Phrase, "phrase", "", "find multi-word anagrams of `phrase`")
+	flag.IntVar(&cfg.MaxWords, "max-words", 3, "maximum number of words in a phrase anagram (0 for no limit)")
+	flag.IntVar(&cfg.MaxResults, "max-results", 100, "maximum number of phrase anagrams (0 for no limit)")
+	flag.Parse()
+
+	f, err := anagram.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cfg.Format = f
+
+	cfg.Signature, err = anagram.ParseSignature(*sig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	cfg.Key, err = anagram.ParseKeyMode(*key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.Workers < 0 {
+		fmt.Fprintln(os.Stderr, "value for -workers cannot be negative")
+		os.Exit(2)
+	}
+
+	if cfg.MinSize < 1 || cfg.MaxSize < 0 {
+		fmt.Fprintln(os.Stderr, "-min must be positive, -max cannot be negative")
+		os.Exit(2)
+	}
+	if cfg.MaxSize > 0 && cfg.MaxSize < cfg.MinSize {
+		fmt.Fprintln(os.Stderr, "-max cannot be less than -min")
+		os.Exit(2)
+	}
+	cfg.Files = flag.Args()
+
+	if cfg.MaxWords < 0 || cfg.MaxResults < 0 {
+		fmt.Fprintln(os.Stderr, "values for -max-words, -max-results cannot be negative")
+		os.Exit(2)
+	}
+
+	if cfg.IndexFile != "" && cfg.Serve == "" && cfg.Phrase == "" {
+		fmt.Fprintln(os.Stderr, "-index can only be used with -serve or -phrase")
+		os.Exit(2)
+	}
+	if cfg.Serve != "" && cfg.Phrase != "" {
+		fmt.Fprintln(os.Stderr, "-serve cannot be used with -phrase")
+		os.Exit(2)
+	}
+	if cfg.IndexFile != "" && len(cfg.Files) > 0 {
+		fmt.Fprintln(os.Stderr, "-index cannot be used with input files")
+		os.Exit(2)
+	}
+
+	return &cfg
+}
+
+// UseIndex reports whether the run works with an index instead of groups.
+func (c *Config) UseIndex() bool {
+	return c.IndexFile != "" || c.SaveIndex != "" || c.Serve != "" || c.Phrase != ""
+}
+
+// PhraseOptions returns the phrase search limits described by the configuration.
+func (c *Config) PhraseOptions() anagram.PhraseOptions {
+	return anagram.PhraseOptions{
+		MaxWords:   c.MaxWords,
+		MaxResults: c.MaxResults,
+	}
+}
+
+// Options returns the anagram options described by the configuration.
+func (c *Config) Options() anagram.Options {
+	return anagram.Options{
+		FoldYo:    c.FoldYo,
+		Signature: c.Signature,
+		Workers:   c.Workers,
+
+		MinSize:      c.MinSize,
+		MaxSize:      c.MaxSize,
+		PreserveCase: c.PreserveCase,
+		Dedup:        c.Dedup,
+		Key:          c.Key,
+	}
+}
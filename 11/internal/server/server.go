@@ -0,0 +1,105 @@
+// Package server exposes an anagram index over HTTP with JSON responses.
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Index answers anagram queries against a fixed dictionary.
+type Index interface {
+	Anagrams(query string) []string
+	SubAnagrams(query string, limit int) []string
+}
+
+// LookupResponse is the result of a lookup query.
+type LookupResponse struct {
+	Query string   `json:"query"`
+	Mode  string   `json:"mode"`
+	Words []string `json:"words"`
+}
+
+// Handler serves lookup requests.
+type Handler struct {
+	ix  Index
+	log *slog.Logger
+}
+
+// NewHandler creates a Handler for the given index.
+func NewHandler(ix Index, log *slog.Logger) *Handler {
+	return &Handler{
+		ix:  ix,
+		log: log,
+	}
+}
+
+// Routes returns the HTTP routes of the handler.
+func (h *Handler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /lookup", h.Lookup)
+	return mux
+}
+
+// Lookup answers GET /lookup?q=word[&mode=exact|sub][&limit=N].
+// The "exact" mode returns words made of exactly the letters of q, the
+// "sub" mode returns words made of a subset of them. The runes "?" and "."
+// in q match any single letter.
+func (h *Handler) Lookup(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if q == "" {
+		h.writeError(w, http.StatusBadRequest, "query parameter q is required")
+		return
+	}
+
+	limit := 0
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			h.writeError(w, http.StatusBadRequest, "invalid limit: "+s)
+			return
+		}
+		limit = n
+	}
+
+	mode := query.Get("mode")
+	var words []string
+	switch mode {
+	case "", "exact":
+		mode = "exact"
+		words = h.ix.Anagrams(q)
+		if limit > 0 && len(words) > limit {
+			words = words[:limit]
+		}
+	case "sub":
+		words = h.ix.SubAnagrams(q, limit)
+	default:
+		h.writeError(w, http.StatusBadRequest, "unknown mode: "+mode)
+		return
+	}
+
+	h.log.Debug("lookup", slog.String("query", q), slog.String("mode", mode), slog.Int("found", len(words)))
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"result": LookupResponse{
+		Query: q,
+		Mode:  mode,
+		Words: words,
+	}})
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, status int, msg string) {
+	h.writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeJSON writes v with the status. The header is already sent when
+// encoding fails, so the error can only be logged.
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.log.Error("write response", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"anagram/anagram"
+)
+
+func TestHandler_Lookup(t *testing.T) {
+	ix, err := anagram.BuildIndex(strings.NewReader("пятак\nпятка\nтяпка\nпят\nка\n"), anagram.Options{})
+	if err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+
+	srv := httptest.NewServer(NewHandler(ix, slog.New(slog.NewTextHandler(io.Discard, nil))).Routes())
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantWords  []string
+	}{
+		{name: "Exact", query: "?q=тяпка", wantStatus: http.StatusOK, wantWords: []string{"пятак", "пятка", "тяпка"}},
+		{name: "Exact limit", query: "?q=тяпка&limit=1", wantStatus: http.StatusOK, wantWords: []string{"пятак"}},
+		{name: "Wildcard", query: "?q=%D1%82%D1%8F%D0%BF%D0%BA%3F", wantStatus: http.StatusOK, wantWords: []string{"пятак", "пятка", "тяпка"}},
+		{name: "Sub", query: "?q=пятак&mode=sub&limit=5", wantStatus: http.StatusOK, wantWords: []string{"пятак", "пятка", "тяпка", "пят", "ка"}},
+		{name: "Missing query", query: "", wantStatus: http.StatusBadRequest},
+		{name: "Bad mode", query: "?q=а&mode=fuzzy", wantStatus: http.StatusBadRequest},
+		{name: "Bad limit", query: "?q=а&limit=-1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + "/lookup" + tt.query)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Result LookupResponse `json:"result"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode error = %v", err)
+			}
+			if !reflect.DeepEqual(body.Result.Words, tt.wantWords) {
+				t.Errorf("words = %v, want %v", body.Result.Words, tt.wantWords)
+			}
+		})
+	}
+}
+
+// failingWriter is a response whose body cannot be written, like one to a
+// client that went away.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestHandler_LookupWriteError(t *testing.T) {
+	var logs bytes.Buffer
+	h := NewHandler(nil, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	h.Lookup(failingWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/lookup", nil))
+
+	if !strings.Contains(logs.String(), "connection reset") {
+		t.Errorf("logs = %q, want the write error", logs.String())
+	}
+}
@@ -1,38 +1,73 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"wb-grep/internal/config"
-	"wb-grep/internal/grep"
+	"wb-grep/internal/search"
+)
+
+// Exit statuses, as in GNU grep.
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
 )
 
 func main() {
 	cfg := config.InitConfig()
 
-	if cfg.Pattern == "" {
-		fmt.Fprintln(os.Stderr, "usage: grep [options] pattern [file]")
-		os.Exit(1)
+	out := bufio.NewWriterSize(os.Stdout, 64*1024)
+	var dst io.Writer = out
+	if cfg.LineBuffered {
+		dst = lineFlusher{w: out}
 	}
 
-	var source io.Reader
-	if cfg.FilePath != "" {
-		file, err := os.Open(cfg.FilePath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error opening file: %v\n", err)
-			os.Exit(1)
-		}
-		defer file.Close()
-		source = file
+	s := search.New(cfg, os.Stderr)
+	var err error
+	if cfg.Follow {
+		// Following ends on an interrupt, with the usual exit status.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err = s.Follow(ctx, dst)
+		stop()
 	} else {
-		source = os.Stdin
+		err = s.Run(dst)
 	}
-
-	g := grep.New(cfg, source)
-	if err := g.Run(os.Stdout); err != nil {
+	if ferr := out.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil && !errors.Is(err, search.ErrFiles) {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
 	}
+
+	switch {
+	case s.Matched() && (err == nil || cfg.Quiet):
+		os.Exit(exitMatch)
+	case err != nil:
+		os.Exit(exitError)
+	default:
+		os.Exit(exitNoMatch)
+	}
+}
+
+// lineFlusher flushes w after every write that ends a line, for
+// --line-buffered.
+type lineFlusher struct {
+	w *bufio.Writer
+}
+
+func (l lineFlusher) Write(p []byte) (int, error) {
+	n, err := l.w.Write(p)
+	if err == nil && bytes.IndexByte(p, '\n') >= 0 {
+		err = l.w.Flush()
+	}
+	return n, err
 }
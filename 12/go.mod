@@ -1,3 +1,5 @@
 module wb-grep
 
 go 1.24.6
+
+require github.com/klauspost/compress v1.18.0
@@ -0,0 +1,170 @@
+// Package ahocorasick finds many fixed strings in a text in a single pass.
+package ahocorasick
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+type node struct {
+	next map[rune]int
+	fail int
+	out  int // index of the nearest node on the fail chain with a pattern, -1 if none
+	size int // length in runes of the pattern ending here, -1 if none
+}
+
+// Automaton is an Aho-Corasick automaton over runes.
+type Automaton struct {
+	nodes    []node
+	fold     bool
+	maxSize  int
+	hasEmpty bool
+}
+
+// New builds an automaton for the patterns. With ignoreCase, runes of the
+// patterns and of the text are compared after simple case folding, and
+// match positions still refer to the original text.
+func New(patterns []string, ignoreCase bool) *Automaton {
+	a := &Automaton{fold: ignoreCase}
+	a.nodes = append(a.nodes, node{next: map[rune]int{}, out: -1, size: -1})
+
+	for _, p := range patterns {
+		if p == "" {
+			a.hasEmpty = true
+			continue
+		}
+
+		cur, size := 0, 0
+		for _, r := range p {
+			r = a.foldRune(r)
+			nxt, ok := a.nodes[cur].next[r]
+			if !ok {
+				a.nodes = append(a.nodes, node{next: map[rune]int{}, out: -1, size: -1})
+				nxt = len(a.nodes) - 1
+				a.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+			size++
+		}
+		a.nodes[cur].size = size
+		a.maxSize = max(a.maxSize, size)
+	}
+
+	a.link()
+	return a
+}
+
+// link computes fail and output links breadth first.
+func (a *Automaton) link() {
+	queue := make([]int, 0, len(a.nodes))
+	for _, child := range a.nodes[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for r, child := range a.nodes[cur].next {
+			f := a.nodes[cur].fail
+			for {
+				if nxt, ok := a.nodes[f].next[r]; ok && nxt != child {
+					a.nodes[child].fail = nxt
+					break
+				}
+				if f == 0 {
+					a.nodes[child].fail = 0
+					break
+				}
+				f = a.nodes[f].fail
+			}
+
+			fail := a.nodes[child].fail
+			if a.nodes[fail].size >= 0 {
+				a.nodes[child].out = fail
+			} else {
+				a.nodes[child].out = a.nodes[fail].out
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+func (a *Automaton) foldRune(r rune) rune {
+	if a.fold {
+		return unicode.ToLower(r)
+	}
+	return r
+}
+
+func (a *Automaton) step(cur int, r rune) int {
+	for {
+		if nxt, ok := a.nodes[cur].next[r]; ok {
+			return nxt
+		}
+		if cur == 0 {
+			return 0
+		}
+		cur = a.nodes[cur].fail
+	}
+}
+
+// Find returns the byte offsets of the leftmost-longest match in s that
+// starts at or after pos, or -1, -1 if there is none.
+func (a *Automaton) Find(s string, pos int) (int, int) {
+	bestStart, bestEnd, bestRune := -1, -1, -1
+	if a.hasEmpty {
+		bestStart, bestEnd, bestRune = pos, pos, 0
+	}
+
+	// starts holds the byte offsets of the last maxSize runes, so that the
+	// start of a match can be found from its length in runes.
+	starts := make([]int, a.maxSize+1)
+	cur := 0
+	for i, n := pos, 0; i < len(s); n++ {
+		// No match ending here or later can start at or before the best one.
+		if bestRune >= 0 && n-a.maxSize+1 > bestRune {
+			break
+		}
+
+		r, w := utf8.DecodeRuneInString(s[i:])
+		starts[n%len(starts)] = i
+		i += w
+
+		cur = a.step(cur, a.foldRune(r))
+		for o := cur; o > 0; o = a.nodes[o].out {
+			size := a.nodes[o].size
+			if size < 0 {
+				continue
+			}
+			startRune := n - size + 1
+			start := starts[startRune%len(starts)]
+			if bestRune < 0 || startRune < bestRune || startRune == bestRune && i > bestEnd {
+				bestStart, bestEnd, bestRune = start, i, startRune
+			}
+		}
+	}
+	return bestStart, bestEnd
+}
+
+// Ends returns the byte offsets where matches that start at s[start:] end,
+// shortest first.
+func (a *Automaton) Ends(s string, start int) []int {
+	var res []int
+	if a.hasEmpty {
+		res = append(res, start)
+	}
+	cur := 0
+	for i := start; i < len(s); {
+		r, w := utf8.DecodeRuneInString(s[i:])
+		nxt, ok := a.nodes[cur].next[a.foldRune(r)]
+		if !ok {
+			break
+		}
+		cur, i = nxt, i+w
+		if a.nodes[cur].size >= 0 {
+			res = append(res, i)
+		}
+	}
+	return res
+}
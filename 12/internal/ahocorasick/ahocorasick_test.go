@@ -0,0 +1,126 @@
+package ahocorasick
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAutomaton_Find(t *testing.T) {
+	tests := []struct {
+		name       string
+		patterns   []string
+		ignoreCase bool
+		text       string
+		pos        int
+		wantStart  int
+		wantEnd    int
+	}{
+		{name: "Single", patterns: []string{"he"}, text: "ushers", wantStart: 2, wantEnd: 4},
+		{name: "Leftmost", patterns: []string{"hers", "she", "he"}, text: "ushers", wantStart: 1, wantEnd: 4},
+		{name: "Longest at same start", patterns: []string{"he", "hers"}, text: "ushers", wantStart: 2, wantEnd: 6},
+		{name: "Longer pattern found later", patterns: []string{"abcd", "b"}, text: "xabcd", wantStart: 1, wantEnd: 5},
+		{name: "From position", patterns: []string{"ab"}, text: "ab ab", pos: 1, wantStart: 3, wantEnd: 5},
+		{name: "No match", patterns: []string{"xyz"}, text: "abc", wantStart: -1, wantEnd: -1},
+		{name: "Ignore case Cyrillic", patterns: []string{"ПРИВЕТ"}, ignoreCase: true, text: "ну привет", wantStart: 5, wantEnd: 17},
+		{name: "Empty pattern", patterns: []string{"", "zz"}, text: "abc", pos: 1, wantStart: 1, wantEnd: 1},
+		{name: "Fail link", patterns: []string{"abcx", "bcd"}, text: "abcd", wantStart: 1, wantEnd: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(tt.patterns, tt.ignoreCase)
+			start, end := a.Find(tt.text, tt.pos)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("Find() = %d, %d, want %d, %d", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestAutomaton_Ends(t *testing.T) {
+	tests := []struct {
+		name       string
+		patterns   []string
+		ignoreCase bool
+		text       string
+		start      int
+		want       []int
+	}{
+		{name: "All at start", patterns: []string{"foo-b", "foo", "f"}, text: "foo-bar", want: []int{1, 3, 5}},
+		{name: "From start", patterns: []string{"ab", "b"}, text: "xab", start: 1, want: []int{3}},
+		{name: "Not a suffix match", patterns: []string{"b"}, text: "ab", want: nil},
+		{name: "Empty pattern", patterns: []string{"", "a"}, text: "a", want: []int{0, 1}},
+		{name: "Ignore case", patterns: []string{"ПРИ"}, ignoreCase: true, text: "привет", want: []int{6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := New(tt.patterns, tt.ignoreCase).Ends(tt.text, tt.start)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Ends() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestAutomaton_FindRandom compares Find with a naive leftmost-longest search.
+func TestAutomaton_FindRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	word := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = "abc"[rnd.Intn(3)]
+		}
+		return string(b)
+	}
+
+	for i := 0; i < 2000; i++ {
+		patterns := make([]string, 1+rnd.Intn(5))
+		for j := range patterns {
+			patterns[j] = word(1 + rnd.Intn(4))
+		}
+		text := word(rnd.Intn(20))
+
+		gotS, gotE := New(patterns, false).Find(text, 0)
+
+		wantS, wantE := -1, -1
+		for s := 0; s < len(text) && wantS < 0; s++ {
+			for _, p := range patterns {
+				if strings.HasPrefix(text[s:], p) && (wantS < 0 || s+len(p) > wantE) {
+					wantS, wantE = s, s+len(p)
+				}
+			}
+		}
+
+		if gotS != wantS || gotE != wantE {
+			t.Fatalf("patterns %q, text %q: got %d, %d, want %d, %d", patterns, text, gotS, gotE, wantS, wantE)
+		}
+	}
+}
+
+func BenchmarkFind(b *testing.B) {
+	patterns := make([]string, 200)
+	for i := range patterns {
+		patterns[i] = fmt.Sprintf("token%03d", i)
+	}
+	line := strings.Repeat("some log line without any of the tokens ", 5) + "token199"
+
+	b.Run("AhoCorasick", func(b *testing.B) {
+		a := New(patterns, false)
+		for i := 0; i < b.N; i++ {
+			a.Find(line, 0)
+		}
+	})
+	b.Run("ContainsLoop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, p := range patterns {
+				if strings.Contains(line, p) {
+					break
+				}
+			}
+		}
+	})
+}
@@ -2,15 +2,21 @@
 package config
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"slices"
+	"strings"
 )
 
 // Config holds the configuration for the grep utility.
 type Config struct {
-	FilePath string
-	Pattern  string
+	Files    []string
+	Pattern  string   // the first operand, used when there are no -e and -f
+	Patterns []string // -e PATTERN and lines of -f FILE, nil when not given
 
 	After      int  // -A N
 	Before     int  // -B N
@@ -19,7 +25,65 @@ type Config struct {
 	IgnoreCase bool // -i
 	Invert     bool // -v
 	Fixed      bool // -F
+	Basic      bool // -G, basic regular expressions translated to RE2
+	Extended   bool // -E, RE2 syntax, the default
+	Perl       bool // -P, the backtracking engine
 	LineNum    bool // -n
+	WordRegexp bool // -w
+	LineRegexp bool // -x
+
+	Recursive   bool     // -r
+	Dereference bool     // -R, like -r but follows all symbolic links
+	Include     []string // --include GLOB
+	Exclude     []string // --exclude GLOB
+	ExcludeDir  []string // --exclude-dir GLOB
+	NoIgnore    bool     // --no-ignore, do not read .gitignore files
+
+	Quiet      bool // -q, --quiet, print nothing and stop at the first match
+	MaxCount   int  // -m NUM, stop reading a file after NUM selected lines, 0 for no limit
+	NoMessages bool // -s, do not report nonexistent and unreadable files
+
+	WithFilename      bool // -H, resolved to the effective value by InitConfig
+	NoFilename        bool // -h
+	FilesWithMatches  bool // -l
+	FilesWithoutMatch bool // -L
+
+	Text       bool // -a, treat binary files as text
+	SkipBinary bool // -I, skip binary files
+	Decompress bool // -z, --decompress, search gzip, bzip2, zstd and tar contents
+
+	Color        bool   // --color, resolved from auto|always|never by InitConfig
+	GrepColors   string // the GREP_COLORS environment variable
+	OnlyMatching bool   // -o
+	ByteOffset   bool   // -b
+	Column       bool   // --column
+	JSON         bool   // --json, print JSON events instead of lines
+	JSONField    string // --json-field PATH, match a dotted field of JSON lines
+
+	Follow       bool // --follow, keep reading files as they grow
+	LineBuffered bool // --line-buffered, flush the output after every line, also set on a terminal
+
+	Workers int // --workers N
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// PatternList returns the patterns to search for.
+func (c *Config) PatternList() []string {
+	if c.Patterns != nil {
+		return c.Patterns
+	}
+	return []string{c.Pattern}
 }
 
 // InitConfig initializes and returns a Config with command-line flags parsed.
@@ -32,7 +96,50 @@ func InitConfig() *Config {
 	flag.BoolVar(&cfg.IgnoreCase, "i", false, "ignore case distinction")
 	flag.BoolVar(&cfg.Invert, "v", false, "invert match")
 	flag.BoolVar(&cfg.Fixed, "F", false, "pattern is fixed string")
+	flag.BoolVar(&cfg.Basic, "G", false, "pattern is a basic regular expression")
+	flag.BoolVar(&cfg.Extended, "E", false, "pattern is an extended regular expression (RE2 syntax, default)")
+	flag.BoolVar(&cfg.Perl, "P", false, "pattern is a Perl regular expression with lookaround and backreferences")
 	flag.BoolVar(&cfg.LineNum, "n", false, "print line number with output lines")
+	flag.BoolVar(&cfg.WordRegexp, "w", false, "match only whole words")
+	flag.BoolVar(&cfg.LineRegexp, "x", false, "match only whole lines")
+
+	var exprs, patternFiles stringList
+	flag.Var(&exprs, "e", "use PATTERN for matching, can be repeated")
+	flag.Var(&patternFiles, "f", "take patterns from FILE, one per line, can be repeated")
+
+	flag.BoolVar(&cfg.Recursive, "r", false, "search directories recursively")
+	flag.BoolVar(&cfg.Dereference, "R", false, "search directories recursively, following all symlinks")
+	flag.Var((*stringList)(&cfg.Include), "include", "search only files whose base name matches GLOB")
+	flag.Var((*stringList)(&cfg.Exclude), "exclude", "skip files whose base name matches GLOB")
+	flag.Var((*stringList)(&cfg.ExcludeDir), "exclude-dir", "skip directories whose base name matches GLOB")
+	flag.BoolVar(&cfg.NoIgnore, "no-ignore", false, "do not skip files listed in .gitignore")
+
+	flag.BoolVar(&cfg.Quiet, "q", false, "print nothing, exit with status 0 on the first match")
+	flag.BoolVar(&cfg.Quiet, "quiet", false, "same as -q")
+	maxCount := flag.Int("m", -1, "stop reading a file after NUM selected lines")
+	flag.BoolVar(&cfg.NoMessages, "s", false, "suppress messages about nonexistent or unreadable files")
+
+	flag.BoolVar(&cfg.WithFilename, "H", false, "print the file name for each match")
+	flag.BoolVar(&cfg.NoFilename, "h", false, "suppress the file name prefix on output")
+	flag.BoolVar(&cfg.FilesWithMatches, "l", false, "print only names of files with matches")
+	flag.BoolVar(&cfg.FilesWithoutMatch, "L", false, "print only names of files without matches")
+
+	flag.BoolVar(&cfg.Text, "a", false, "process binary files as text")
+	flag.BoolVar(&cfg.SkipBinary, "I", false, "skip binary files")
+	flag.BoolVar(&cfg.Decompress, "z", false, "search the contents of compressed files and tar archives")
+	flag.BoolVar(&cfg.Decompress, "decompress", false, "same as -z")
+
+	color := flag.String("color", "never", "highlight matches: auto, always or never")
+	flag.BoolVar(&cfg.OnlyMatching, "o", false, "print only the matched parts of lines")
+	flag.BoolVar(&cfg.ByteOffset, "b", false, "print the byte offset with output lines")
+	flag.BoolVar(&cfg.Column, "column", false, "print the column of the first match")
+	flag.BoolVar(&cfg.JSON, "json", false, "print results as JSON lines: begin, match, context and end events")
+	flag.StringVar(&cfg.JSONField, "json-field", "", "match against the field at a dotted PATH of JSON input lines, like a.b.0")
+
+	flag.BoolVar(&cfg.Follow, "follow", false, "keep reading files as they grow, across truncation and rotation")
+	flag.BoolVar(&cfg.LineBuffered, "line-buffered", false, "flush the output after every line")
+
+	flag.IntVar(&cfg.Workers, "workers", runtime.GOMAXPROCS(0), "number of files searched concurrently")
 	flag.Parse()
 
 	if cfg.After < 0 || cfg.Before < 0 || cfg.Context < 0 {
@@ -40,9 +147,70 @@ func InitConfig() *Config {
 		os.Exit(2)
 	}
 
-	cfg.Pattern = flag.Arg(0)
-	if flag.NArg() > 1 {
-		cfg.FilePath = flag.Arg(1)
+	if cfg.JSON && (cfg.CountOnly || cfg.FilesWithMatches || cfg.FilesWithoutMatch || cfg.Quiet) {
+		fmt.Fprintln(os.Stderr, "--json cannot be used with -c, -l, -L or -q")
+		os.Exit(2)
+	}
+
+	if cfg.Follow && (cfg.Recursive || cfg.Dereference || cfg.CountOnly || cfg.FilesWithMatches || cfg.FilesWithoutMatch || cfg.Decompress) {
+		fmt.Fprintln(os.Stderr, "--follow cannot be used with -r, -R, -c, -l, -L or -z")
+		os.Exit(2)
+	}
+
+	engines := 0
+	for _, set := range []bool{cfg.Fixed, cfg.Basic, cfg.Extended, cfg.Perl} {
+		if set {
+			engines++
+		}
+	}
+	if engines > 1 {
+		fmt.Fprintln(os.Stderr, "conflicting matchers specified: use only one of -F, -G, -E, -P")
+		os.Exit(2)
+	}
+
+	if cfg.Workers < 1 {
+		fmt.Fprintln(os.Stderr, "value for --workers must be positive")
+		os.Exit(2)
+	}
+
+	switch *color {
+	case "always":
+		cfg.Color = true
+	case "auto":
+		cfg.Color = isTerminal(os.Stdout) && os.Getenv("TERM") != "dumb"
+	case "never":
+	default:
+		fmt.Fprintf(os.Stderr, "invalid value for --color: %q\n", *color)
+		os.Exit(2)
+	}
+	cfg.GrepColors = os.Getenv("GREP_COLORS")
+
+	// Output is block buffered, except on a terminal, as in GNU grep.
+	cfg.LineBuffered = cfg.LineBuffered || isTerminal(os.Stdout)
+
+	if len(exprs) > 0 || len(patternFiles) > 0 {
+		cfg.Patterns = append([]string{}, exprs...)
+		for _, path := range patternFiles {
+			patterns, err := readPatterns(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reading patterns: %v\n", err)
+				os.Exit(2)
+			}
+			cfg.Patterns = append(cfg.Patterns, patterns...)
+		}
+		cfg.Files = flag.Args()
+	} else {
+		if flag.NArg() == 0 {
+			fmt.Fprintln(os.Stderr, "usage: grep [options] pattern [file ...]")
+			os.Exit(2)
+		}
+		cfg.Pattern = flag.Arg(0)
+		cfg.Files = flag.Args()[1:]
+	}
+
+	if cfg.Follow && (len(cfg.Files) == 0 || slices.Contains(cfg.Files, "-")) {
+		fmt.Fprintln(os.Stderr, "--follow needs file operands, standard input is searched as it arrives without it")
+		os.Exit(2)
 	}
 
 	if cfg.Context > 0 {
@@ -50,5 +218,47 @@ func InitConfig() *Config {
 		cfg.Before = cfg.Context
 	}
 
+	if cfg.Dereference {
+		cfg.Recursive = true
+	}
+
+	switch {
+	case *maxCount == 0:
+		// No line can be selected, so there is nothing to read.
+		os.Exit(1)
+	case *maxCount > 0:
+		cfg.MaxCount = *maxCount
+	}
+
+	cfg.WithFilename = !cfg.NoFilename &&
+		(cfg.WithFilename || len(cfg.Files) > 1 || cfg.Recursive)
+
 	return &cfg
 }
+
+// isTerminal reports whether f is a character device, which is what
+// --color=auto and the output buffering check for.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// readPatterns reads one pattern per line from path, "-" means stdin.
+func readPatterns(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+	return patterns, scanner.Err()
+}
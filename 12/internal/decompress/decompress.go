@@ -0,0 +1,112 @@
+// Package decompress detects compressed input by its magic bytes and
+// unpacks it on the fly. Tar archives are split into their members.
+package decompress
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// format is a compression format recognized by the first bytes of a stream.
+type format struct {
+	magic []byte
+	open  func(io.Reader) (io.ReadCloser, error)
+}
+
+var formats = []format{
+	{magic: []byte{0x1f, 0x8b}, open: openGzip},
+	{magic: []byte("BZh"), open: openBzip2},
+	{magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, open: openZstd},
+}
+
+func openGzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func openBzip2(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(bzip2.NewReader(r)), nil
+}
+
+func openZstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// NewReader returns the decompressed content of r, or r itself if it does
+// not start with the magic bytes of gzip, bzip2 or zstd.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	for _, f := range formats {
+		if bytes.HasPrefix(head, f.magic) {
+			return f.open(br)
+		}
+	}
+	return io.NopCloser(br), nil
+}
+
+// Walk calls fn with the decompressed content of r and an empty member
+// name. If the content is a tar archive, fn is called for every regular
+// file in it instead, with the member name and its decompressed content.
+// Walk stops at the first error returned by fn and returns it.
+func Walk(r io.Reader, fn func(member string, r io.Reader) error) error {
+	rc, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	br := bufio.NewReader(rc)
+	head, err := br.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if !isTar(head) {
+		return fn("", br)
+	}
+
+	tr := tar.NewReader(br)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		mr, err := NewReader(tr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		err = fn(hdr.Name, mr)
+		mr.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isTar reports whether head is the header block of a POSIX or GNU tar
+// archive, which have "ustar" at offset 257.
+func isTar(head []byte) bool {
+	return len(head) >= 262 && string(head[257:262]) == "ustar"
+}
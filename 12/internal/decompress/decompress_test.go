@@ -0,0 +1,170 @@
+package decompress
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const text = "apple\nbanana\n"
+
+// bzip2Text is text compressed with the bzip2 tool, the standard library
+// has no bzip2 writer.
+var bzip2Text = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x5a, 0xf1,
+	0xa9, 0x28, 0x00, 0x00, 0x02, 0xc1, 0x80, 0x00, 0x10, 0x32, 0x05, 0x40,
+	0x00, 0x20, 0x00, 0x21, 0xa7, 0xa8, 0xc4, 0x21, 0x80, 0x3a, 0x26, 0xd4,
+	0xb5, 0x07, 0x8b, 0xb9, 0x22, 0x9c, 0x28, 0x48, 0x2d, 0x78, 0xd4, 0x94,
+	0x00,
+}
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, s string) []byte {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	return w.EncodeAll([]byte(s), nil)
+}
+
+func tarred(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	if err := w.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(files); i += 2 {
+		hdr := &tar.Header{Name: files[i], Mode: 0o644, Size: int64(len(files[i+1]))}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, files[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewReader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"plain", []byte(text)},
+		{"gzip", gzipped(t, text)},
+		{"bzip2", bzip2Text},
+		{"zstd", zstded(t, text)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != text {
+				t.Errorf("got %q, want %q", got, text)
+			}
+		})
+	}
+
+	for _, short := range []string{"", "a"} {
+		r, err := NewReader(bytes.NewReader([]byte(short)))
+		if err != nil {
+			t.Fatalf("NewReader(%q) error = %v", short, err)
+		}
+		if got, _ := io.ReadAll(r); string(got) != short {
+			t.Errorf("NewReader(%q) read %q", short, got)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	archive := tarred(t,
+		"a.log", "apple\n",
+		"dir/b.log.gz", string(gzipped(t, "banana\n")),
+	)
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  []string
+	}{
+		{"plain", []byte(text), []string{"", text}},
+		{"compressed", zstded(t, text), []string{"", text}},
+		{"tar", archive, []string{"a.log", "apple\n", "dir/b.log.gz", "banana\n"}},
+		{"tar.gz", gzipped(t, string(archive)), []string{"a.log", "apple\n", "dir/b.log.gz", "banana\n"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := Walk(bytes.NewReader(tt.input), func(member string, r io.Reader) error {
+				data, err := io.ReadAll(r)
+				got = append(got, member, string(data))
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Walk() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWalk_Stop(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := Walk(bytes.NewReader(tarred(t, "a", "1", "b", "2")), func(string, io.Reader) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Walk() = %v after %d calls, want the error of the first call", err, calls)
+	}
+}
+
+func TestWalk_Corrupt(t *testing.T) {
+	data := gzipped(t, text)
+	data[len(data)-5] ^= 0xff // break the checksum
+
+	err := Walk(bytes.NewReader(data), func(_ string, r io.Reader) error {
+		_, err := io.ReadAll(r)
+		return err
+	})
+	if err == nil {
+		t.Error("Walk() error = nil, want a checksum error")
+	}
+}
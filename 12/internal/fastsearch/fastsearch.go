@@ -0,0 +1,100 @@
+// Package fastsearch finds a fixed byte string in large buffers. It skips
+// to candidates with bytes.IndexByte, which uses SIMD instructions where
+// available, on the rarest byte of the pattern, and switches to
+// Boyer-Moore-Horspool when candidates keep turning out false.
+package fastsearch
+
+import "bytes"
+
+// maxFalse is the number of false candidates after which a search gives
+// up on the byte skip loop.
+const maxFalse = 32
+
+// Searcher finds a pattern. It is safe for concurrent use.
+type Searcher struct {
+	pattern []byte
+	rare    byte // the byte the skip loop looks for
+	rareOff int  // its offset in the pattern
+	shift   [256]int
+}
+
+// New creates a Searcher for a non-empty pattern.
+func New(pattern []byte) *Searcher {
+	s := &Searcher{pattern: pattern}
+
+	s.rareOff = 0
+	for i, c := range pattern {
+		if rank[c] < rank[pattern[s.rareOff]] {
+			s.rareOff = i
+		}
+	}
+	s.rare = pattern[s.rareOff]
+
+	n := len(pattern)
+	for i := range s.shift {
+		s.shift[i] = n
+	}
+	for i, c := range pattern[:n-1] {
+		s.shift[c] = n - 1 - i
+	}
+	return s
+}
+
+// Index returns the offset of the first occurrence of the pattern in data,
+// or -1.
+func (s *Searcher) Index(data []byte) int {
+	n := len(s.pattern)
+	if n == 1 {
+		return bytes.IndexByte(data, s.rare)
+	}
+
+	falses := 0
+	for i := 0; i+n <= len(data); {
+		// The rare byte of a match at i is at i+rareOff, and the match
+		// must fit into data.
+		j := bytes.IndexByte(data[i+s.rareOff:len(data)-n+1+s.rareOff], s.rare)
+		if j < 0 {
+			return -1
+		}
+		i += j
+		if bytes.Equal(data[i:i+n], s.pattern) {
+			return i
+		}
+
+		i++
+		if falses++; falses > maxFalse {
+			return s.horspool(data, i)
+		}
+	}
+	return -1
+}
+
+// horspool searches data from offset i with the Boyer-Moore-Horspool
+// algorithm: the byte under the end of the pattern tells how far it can
+// be shifted.
+func (s *Searcher) horspool(data []byte, i int) int {
+	n := len(s.pattern)
+	last := s.pattern[n-1]
+	for i+n <= len(data) {
+		c := data[i+n-1]
+		if c == last && bytes.Equal(data[i:i+n-1], s.pattern[:n-1]) {
+			return i
+		}
+		i += s.shift[c]
+	}
+	return -1
+}
+
+// rank orders bytes by how common they are in text and source code, lower
+// is rarer. Bytes that are not listed are taken as rare.
+var rank = func() [256]int {
+	var r [256]int
+	// From the most to the least common.
+	const common = " etaoinsrhldcumfpgwybvkxjqz\n\t\r" +
+		"ETAOINSRHLDCUMFPGWYBVKXJQZ" +
+		"0123456789.,;:_-=()/\"'{}[]<>*#"
+	for i := 0; i < len(common); i++ {
+		r[common[i]] = len(common) - i
+	}
+	return r
+}()
@@ -0,0 +1,86 @@
+package fastsearch
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSearcher_Index(t *testing.T) {
+	tests := []struct {
+		pattern string
+		data    string
+		want    int
+	}{
+		{"a", "bbab", 2},
+		{"a", "bbb", -1},
+		{"abc", "abc", 0},
+		{"abc", "ababc", 2},
+		{"abc", "ab", -1},
+		{"zq", "qzzq", 2},
+		{"zq", "qzzx", -1},
+		{"ошибка", "нет ошибки, но есть ошибка", 35},
+		{"xa", strings.Repeat("x", 100) + "a", 99},
+		{"xa", strings.Repeat("x", 100), -1},
+	}
+
+	for _, tt := range tests {
+		if got := New([]byte(tt.pattern)).Index([]byte(tt.data)); got != tt.want {
+			t.Errorf("Index(%q, %q) = %d, want %d", tt.pattern, tt.data, got, tt.want)
+		}
+	}
+}
+
+// TestSearcher_IndexRandom covers both the skip loop and the switch to
+// Horspool on texts with few different bytes.
+func TestSearcher_IndexRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		alphabet := "ab\n"[:rnd.Intn(3)+1]
+		pattern := make([]byte, rnd.Intn(6)+1)
+		for j := range pattern {
+			pattern[j] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		data := make([]byte, rnd.Intn(300))
+		for j := range data {
+			data[j] = alphabet[rnd.Intn(len(alphabet))]
+		}
+
+		if got, want := New(pattern).Index(data), bytes.Index(data, pattern); got != want {
+			t.Fatalf("Index(%q, %q) = %d, want %d", pattern, data, got, want)
+		}
+	}
+}
+
+func benchmarkData() []byte {
+	line := "2024-05-01T12:00:00Z INFO request served path=/api/v1/items status=200 duration=12ms\n"
+	data := []byte(strings.Repeat(line, 100000))
+	return append(data, "2024-05-01T12:00:01Z ERROR upstream timeout\n"...)
+}
+
+func BenchmarkIndex(b *testing.B) {
+	data := benchmarkData()
+	s := New([]byte("timeout"))
+	b.SetBytes(int64(len(data)))
+	for range b.N {
+		s.Index(data)
+	}
+}
+
+func BenchmarkIndexHorspool(b *testing.B) {
+	data := benchmarkData()
+	s := New([]byte("timeout"))
+	b.SetBytes(int64(len(data)))
+	for range b.N {
+		s.horspool(data, 0)
+	}
+}
+
+func BenchmarkBytesIndex(b *testing.B) {
+	data := benchmarkData()
+	b.SetBytes(int64(len(data)))
+	for range b.N {
+		bytes.Index(data, []byte("timeout"))
+	}
+}
@@ -0,0 +1,138 @@
+// Package follow reads files that keep growing, like tail -F. Truncated
+// files are read again from the start, and when the path is given to a new
+// file, as log rotation does, the rest of the old file is read before the
+// new one. Both are reported with ErrRestart.
+package follow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrRestart is returned by Read, with no data, when reading starts over
+// at the beginning of a truncated file or of the file that replaced the
+// followed one. Reading can go on after it.
+var ErrRestart = errors.New("file restarted")
+
+// Reader reads a file by path and waits for more data at its end instead
+// of returning io.EOF. It polls the file every interval and ends with
+// io.EOF once its context is done.
+type Reader struct {
+	ctx      context.Context
+	path     string
+	interval time.Duration
+	f        *os.File
+	info     os.FileInfo
+	off      int64
+
+	// The file now at the path, opened when the followed file was
+	// replaced and read once the old one is read to its end.
+	next     *os.File
+	nextInfo os.FileInfo
+}
+
+// Open opens the file at path for following.
+func Open(ctx context.Context, path string, interval time.Duration) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Reader{ctx: ctx, path: path, interval: interval, f: f, info: info}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for {
+		if r.ctx.Err() != nil {
+			return 0, io.EOF
+		}
+
+		n, err := r.f.Read(p)
+		r.off += int64(n)
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+
+		// At the end of the file, see whether it was truncated or
+		// replaced before waiting for more.
+		again, restart, err := r.check()
+		switch {
+		case err != nil:
+			return 0, err
+		case restart:
+			return 0, ErrRestart
+		case again:
+			continue
+		}
+
+		t := time.NewTimer(r.interval)
+		select {
+		case <-r.ctx.Done():
+			t.Stop()
+			return 0, io.EOF
+		case <-t.C:
+		}
+	}
+}
+
+// check starts over when the file shrank below the read offset, and opens
+// the file now at the path when it is another one. While the path is
+// missing, between the rename and the creation of a new file, the old file
+// is still followed. It reports whether there may be more to read and
+// whether reading starts over.
+func (r *Reader) check() (again, restart bool, err error) {
+	if r.next != nil {
+		r.f.Close()
+		r.f, r.info, r.off = r.next, r.nextInfo, 0
+		r.next, r.nextInfo = nil, nil
+		return true, true, nil
+	}
+
+	info, err := r.f.Stat()
+	if err != nil {
+		return false, false, err
+	}
+	if info.Size() < r.off {
+		if _, err := r.f.Seek(0, io.SeekStart); err != nil {
+			return false, false, err
+		}
+		r.off = 0
+		return true, true, nil
+	}
+
+	info, err = os.Stat(r.path)
+	if err != nil || os.SameFile(info, r.info) {
+		return false, false, nil
+	}
+	f, err := os.Open(r.path)
+	if err != nil {
+		return false, false, nil
+	}
+	if info, err = f.Stat(); err != nil {
+		f.Close()
+		return false, false, nil
+	}
+
+	// Lines written to the old file between the last read and the rename
+	// are read before switching.
+	r.next, r.nextInfo = f, info
+	return true, false, nil
+}
+
+// Close closes the file being followed.
+func (r *Reader) Close() error {
+	if r.next != nil {
+		r.next.Close()
+	}
+	return r.f.Close()
+}
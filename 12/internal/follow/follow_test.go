@@ -0,0 +1,153 @@
+package follow
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const interval = 5 * time.Millisecond
+
+// readLines follows path and sends every line read, and "restart" when
+// reading starts over.
+func readLines(t *testing.T, ctx context.Context, path string) <-chan string {
+	t.Helper()
+	r, err := Open(ctx, path, interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		defer r.Close()
+		br := bufio.NewReader(r)
+		for {
+			s, err := br.ReadString('\n')
+			if s != "" {
+				lines <- s
+			}
+			switch {
+			case errors.Is(err, ErrRestart):
+				lines <- "restart"
+			case err != nil:
+				return
+			}
+		}
+	}()
+	return lines
+}
+
+func expect(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		select {
+		case got := <-lines:
+			if got != w {
+				t.Fatalf("got %q, want %q", got, w)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func appendFile(t *testing.T, path, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, path string)
+		want   []string
+	}{
+		{
+			name: "append",
+			change: func(t *testing.T, path string) {
+				appendFile(t, path, "second\nthi")
+				time.Sleep(10 * interval)
+				appendFile(t, path, "rd\n")
+			},
+			want: []string{"second\n", "third\n"},
+		},
+		{
+			name: "truncate",
+			change: func(t *testing.T, path string) {
+				if err := os.Truncate(path, 0); err != nil {
+					t.Fatal(err)
+				}
+				appendFile(t, path, "new\n")
+			},
+			want: []string{"restart", "new\n"},
+		},
+		{
+			name: "rotate",
+			change: func(t *testing.T, path string) {
+				if err := os.Rename(path, path+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendFile(t, path+".1", "late\n")
+				time.Sleep(10 * interval)
+				appendFile(t, path, "rotated\n")
+			},
+			want: []string{"late\n", "restart", "rotated\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "log")
+			appendFile(t, path, "first\n")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			lines := readLines(t, ctx, path)
+			expect(t, lines, "first\n")
+
+			tt.change(t, path)
+			expect(t, lines, tt.want...)
+
+			cancel()
+			select {
+			case s, ok := <-lines:
+				if ok {
+					t.Errorf("got %q after cancel", s)
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("Read does not return after cancel")
+			}
+		})
+	}
+}
+
+func TestReader_EOFAfterCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+	appendFile(t, path, "partial")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := Open(ctx, path, interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	time.AfterFunc(10*interval, cancel)
+	data, err := io.ReadAll(r)
+	if err != nil || string(data) != "partial" {
+		t.Errorf("ReadAll() = %q, %v, want %q", data, err, "partial")
+	}
+}
@@ -0,0 +1,110 @@
+package grep
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// translateBRE translates a POSIX basic regular expression for -G into RE2
+// syntax. The GNU extensions \+, \?, \|, \< and \> are supported, while
+// backreferences are left to -P.
+func translateBRE(p string) (string, error) {
+	var sb strings.Builder
+
+	// "*" is a literal at the start of an expression or a group, after an
+	// alternation and after a leading "^".
+	start := true
+	for i := 0; i < len(p); {
+		c := p[i]
+		switch {
+		case c == '\\':
+			if i+1 == len(p) {
+				return "", errors.New("trailing backslash (\\)")
+			}
+			r, w := utf8.DecodeRuneInString(p[i+1:])
+			i += 1 + w
+
+			switch r {
+			case '(', '|':
+				sb.WriteRune(r)
+				start = true
+				continue
+			case ')', '+', '?':
+				sb.WriteRune(r)
+			case '{':
+				end := strings.Index(p[i:], `\}`)
+				if end < 0 {
+					return "", errors.New(`unmatched \{`)
+				}
+				sb.WriteString("{" + p[i:i+end] + "}")
+				i += end + 2
+			case '<', '>':
+				sb.WriteString(`\b`)
+			case 'w', 'W', 's', 'S', 'b', 'B':
+				sb.WriteString(`\` + string(r))
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				return "", fmt.Errorf("backreference \\%c is not supported, use -P", r)
+			default:
+				sb.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		case c == '[':
+			end, err := bracketEnd(p, i)
+			if err != nil {
+				return "", err
+			}
+			// Backslashes are literal in POSIX bracket expressions.
+			sb.WriteString(strings.ReplaceAll(p[i:end], `\`, `\\`))
+			i = end
+		case c == '*' && start:
+			sb.WriteString(`\*`)
+			i++
+		case c == '^' && start:
+			sb.WriteByte('^')
+			i++
+			continue
+		case c == '$' && (i+1 == len(p) || strings.HasPrefix(p[i+1:], `\)`) || strings.HasPrefix(p[i+1:], `\|`)):
+			sb.WriteByte('$')
+			i++
+		case strings.IndexByte("^$+?(){}|", c) >= 0:
+			sb.WriteString(`\` + string(c))
+			i++
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+		start = false
+	}
+	return sb.String(), nil
+}
+
+// bracketEnd returns the offset right after the bracket expression that
+// starts at p[i].
+func bracketEnd(p string, i int) (int, error) {
+	j := i + 1
+	if j < len(p) && p[j] == '^' {
+		j++
+	}
+	// A "]" right after the opening bracket is a literal.
+	if j < len(p) && p[j] == ']' {
+		j++
+	}
+
+	for j < len(p) {
+		switch {
+		case p[j] == ']':
+			return j + 1, nil
+		case strings.HasPrefix(p[j:], "[:"), strings.HasPrefix(p[j:], "[="), strings.HasPrefix(p[j:], "[."):
+			end := strings.Index(p[j+2:], string(p[j+1])+"]")
+			if end < 0 {
+				return 0, errors.New("unmatched [")
+			}
+			j += end + 4
+		default:
+			j++
+		}
+	}
+	return 0, errors.New("unmatched [")
+}
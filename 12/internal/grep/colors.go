@@ -0,0 +1,108 @@
+package grep
+
+import (
+	"strings"
+
+	"wb-grep/internal/config"
+)
+
+// Colors holds the SGR sequences used with --color, in the format of the
+// GREP_COLORS environment variable. An empty sequence means no color, so
+// the zero value prints everything as is.
+type Colors struct {
+	Match        string // ms, matched text in selected lines
+	ContextMatch string // mc, matched text in context lines
+	Selected     string // sl, the rest of selected lines
+	Context      string // cx, the rest of context lines
+	File         string // fn, file names
+	Line         string // ln, line and column numbers
+	Byte         string // bn, byte offsets
+	Sep          string // se, separators
+	Reverse      bool   // rv, swap sl and cx with -v
+	NoClear      bool   // ne, do not clear to the end of line after a sequence
+}
+
+// DefaultColors are the colors of GNU grep.
+var DefaultColors = Colors{
+	Match:        "01;31",
+	ContextMatch: "01;31",
+	File:         "35",
+	Line:         "32",
+	Byte:         "32",
+	Sep:          "36",
+}
+
+// ParseColors applies a GREP_COLORS value such as "ms=01;32:ln=33:ne" to
+// the default colors. Unknown capabilities are ignored, as grep does.
+func ParseColors(s string) Colors {
+	c := DefaultColors
+	for _, field := range strings.Split(s, ":") {
+		key, val, _ := strings.Cut(field, "=")
+		switch key {
+		case "mt":
+			c.Match, c.ContextMatch = val, val
+		case "ms":
+			c.Match = val
+		case "mc":
+			c.ContextMatch = val
+		case "sl":
+			c.Selected = val
+		case "cx":
+			c.Context = val
+		case "fn":
+			c.File = val
+		case "ln":
+			c.Line = val
+		case "bn":
+			c.Byte = val
+		case "se":
+			c.Sep = val
+		case "rv":
+			c.Reverse = true
+		case "ne":
+			c.NoClear = true
+		}
+	}
+	return c
+}
+
+// colorsOf returns the colors of cfg, the zero value when colors are off.
+// With rv and -v the colors of selected and context lines are swapped.
+func colorsOf(cfg *config.Config) Colors {
+	if !cfg.Color {
+		return Colors{}
+	}
+	c := ParseColors(cfg.GrepColors)
+	if c.Reverse && cfg.Invert {
+		c.Selected, c.Context = c.Context, c.Selected
+	}
+	return c
+}
+
+// wrap surrounds s with the sequence sgr, if there is one.
+func (c Colors) wrap(sgr, s string) string {
+	if sgr == "" || s == "" {
+		return s
+	}
+	return c.start(sgr) + s + c.end()
+}
+
+// start returns the escape that turns on sgr, or "" if there is none.
+func (c Colors) start(sgr string) string {
+	if sgr == "" {
+		return ""
+	}
+	return "\x1b[" + sgr + "m" + c.clear()
+}
+
+// end returns the escape that turns all colors off.
+func (c Colors) end() string {
+	return "\x1b[m" + c.clear()
+}
+
+func (c Colors) clear() string {
+	if c.NoClear {
+		return ""
+	}
+	return "\x1b[K"
+}
@@ -0,0 +1,133 @@
+package grep
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"wb-grep/internal/fastsearch"
+)
+
+// fixed reports whether the fast path of runFixed applies: a single fixed
+// string that is matched case-sensitively and without context, so that
+// only the lines around hits have to be looked at.
+func (g *Grep) fixed() bool {
+	patterns := g.cfg.PatternList()
+	return g.cfg.Fixed && len(patterns) == 1 && patterns[0] != "" &&
+		!strings.ContainsAny(patterns[0], "\r\n") &&
+		!g.cfg.IgnoreCase && !g.cfg.Invert &&
+		g.cfg.Before == 0 && g.cfg.After == 0 && g.cfg.JSONField == ""
+}
+
+// fixedScan is the state of runFixed between buffers.
+type fixedScan struct {
+	g      *Grep
+	search *fastsearch.Searcher
+	verify bool  // -w and -x need the matcher to confirm hits
+	lines  bool  // line numbers are needed
+	base   int64 // offset of the buffer in the input
+	num    int   // number of lines before the counted part of the buffer
+}
+
+// runFixed searches whole buffers for the pattern and splits out only the
+// lines that contain a hit. src is read in chunks that end at a line
+// boundary and grow to hold lines of any length.
+func (g *Grep) runFixed(src *bufio.Reader) error {
+	s := &fixedScan{
+		g:      g,
+		search: fastsearch.New([]byte(g.cfg.PatternList()[0])),
+		verify: g.cfg.WordRegexp || g.cfg.LineRegexp,
+		lines:  g.cfg.LineNum || g.cfg.JSON,
+	}
+	buf := make([]byte, 0, 256*1024)
+	for {
+		if len(buf) == cap(buf) {
+			buf = append(buf, 0)[:len(buf)]
+		}
+		n, err := src.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		eof := errors.Is(err, io.EOF)
+		restart := errors.Is(err, ErrRestart)
+		if err != nil && !eof && !restart {
+			return err
+		}
+
+		region := buf
+		if !eof && !restart {
+			end := bytes.LastIndexByte(buf, '\n')
+			if end < 0 {
+				continue
+			}
+			region = buf[:end+1]
+		}
+
+		done, err := s.scan(region)
+		if err != nil || done || eof {
+			return err
+		}
+		buf = buf[:copy(buf, buf[len(region):])]
+		if restart {
+			s.base, s.num = 0, 0
+			g.out.restart()
+		}
+	}
+}
+
+// scan searches a buffer of whole lines, the last of which may lack its
+// newline at the end of the input. It reports whether the search is over.
+func (s *fixedScan) scan(data []byte) (bool, error) {
+	g := s.g
+	counted := 0
+	defer func() {
+		s.base += int64(len(data))
+	}()
+
+	for pos := 0; pos < len(data); {
+		i := s.search.Index(data[pos:])
+		if i < 0 {
+			break
+		}
+		hit := pos + i
+		start := bytes.LastIndexByte(data[pos:hit], '\n') + pos + 1
+		end := len(data)
+		if k := bytes.IndexByte(data[hit:], '\n'); k >= 0 {
+			end = hit + k
+		}
+		pos = end + 1
+
+		if s.lines {
+			s.num += bytes.Count(data[counted:start], []byte{'\n'})
+			counted = start
+		}
+		text := strings.TrimSuffix(string(data[start:end]), "\r")
+
+		if s.verify {
+			ok, err := g.match.Match(text)
+			if err != nil || !ok {
+				if err != nil {
+					return true, err
+				}
+				continue
+			}
+		}
+
+		g.matches++
+		if g.quiet {
+			if g.firstOnly() {
+				return true, nil
+			}
+		} else if err := g.out.print(line{num: s.num + 1, off: s.base + int64(start), text: text}, true); err != nil {
+			return true, err
+		}
+		if g.cfg.MaxCount > 0 && g.matches >= g.cfg.MaxCount {
+			return true, nil
+		}
+	}
+
+	if s.lines {
+		s.num += bytes.Count(data[counted:], []byte{'\n'})
+	}
+	return false, nil
+}
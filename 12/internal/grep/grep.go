@@ -3,150 +3,306 @@ package grep
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
-	"regexp"
 	"strings"
 	"wb-grep/internal/config"
 )
 
+// StdinName is the file name used for standard input.
+const StdinName = "(standard input)"
+
 type Grep struct {
-	cfg    *config.Config
-	source io.Reader
+	cfg     *config.Config
+	source  io.Reader
+	name    string
+	match   Matcher
+	matches int
+
+	// State of Run.
+	out    output
+	json   *jsonPrinter
+	binary bool
+	quiet  bool
 }
 
 func New(cfg *config.Config, source io.Reader) *Grep {
 	return &Grep{
 		cfg:    cfg,
 		source: source,
+		name:   StdinName,
+	}
+}
+
+// NewFile creates a Grep for a named source. The matcher is shared between
+// files so that the pattern is compiled once; nil means compile it in Run.
+func NewFile(cfg *config.Config, name string, source io.Reader, m Matcher) *Grep {
+	return &Grep{
+		cfg:    cfg,
+		source: source,
+		name:   name,
+		match:  m,
 	}
 }
 
+// ErrRestart is returned by sources that start over, like followed files
+// that were truncated or rotated. Run then numbers lines and bytes from
+// the start again, forgets the before context and separates the context
+// groups of the new content from the old, and reads on.
+var ErrRestart = errors.New("source restarted")
+
+// line is an input line together with its 1-based number and the byte
+// offset of its start.
+type line struct {
+	num  int
+	off  int64
+	text string
+}
+
+// Run filters the source line by line and writes the result to dst as it
+// goes. Only the last -B lines are kept in memory for the before context,
+// the after context is handled with a countdown. Simple fixed string
+// searches look for the pattern in whole buffers instead, see runFixed.
 func (g *Grep) Run(dst io.Writer) error {
-	scanner := bufio.NewScanner(g.source)
-	lines := make([]string, 0)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	if g.match == nil {
+		m, err := NewMatcher(g.cfg)
+		if err != nil {
+			return err
+		}
+		g.match = m
 	}
-	if err := scanner.Err(); err != nil {
-		return err
+
+	src := bufio.NewReaderSize(g.source, 64*1024)
+	// Look only at the first chunk that arrives, so that reading from a
+	// pipe does not block until the buffer fills up.
+	src.Peek(1)
+	head, _ := src.Peek(src.Buffered())
+
+	nul := -1
+	if !g.cfg.Text {
+		nul = bytes.IndexByte(head, 0)
+	}
+	g.binary = nul >= 0
+	if g.binary && g.cfg.SkipBinary {
+		return nil
+	}
+
+	// At most the file name is printed in these modes, lines are just counted.
+	g.quiet = g.cfg.Quiet || g.cfg.CountOnly || g.cfg.FilesWithMatches || g.cfg.FilesWithoutMatch || g.binary
+
+	g.out = newPrinter(g.cfg, dst, g.name, g.match)
+	if g.cfg.JSON {
+		g.json = &jsonPrinter{dst: dst, name: g.name, match: g.match, invert: g.cfg.Invert}
+		g.out = g.json
 	}
 
-	matches, err := g.findMatches(lines)
+	var err error
+	if g.fixed() {
+		err = g.runFixed(src)
+	} else {
+		err = g.runLines(src)
+	}
 	if err != nil {
 		return err
 	}
 
-	if g.cfg.CountOnly {
-		count := 0
-		for _, m := range matches {
-			if m {
-				count++
-			}
+	if g.json != nil {
+		var binaryOffset *int64
+		if g.binary {
+			off := int64(nul)
+			binaryOffset = &off
 		}
-
-		fmt.Fprintf(dst, "%d\n", count)
-		return nil
+		return g.json.end(g.matches, binaryOffset)
 	}
+	return g.summary(dst, g.binary)
+}
 
-	toOutput := g.calculateOutputLines(matches, len(lines))
-
-	g.outputLines(dst, lines, toOutput, matches)
-
-	return nil
+// firstOnly reports whether the first selected line ends the search.
+func (g *Grep) firstOnly() bool {
+	return g.cfg.Quiet || g.cfg.FilesWithMatches || g.cfg.FilesWithoutMatch || g.binary && !g.cfg.CountOnly
 }
 
-func (g *Grep) findMatches(lines []string) ([]bool, error) {
-	matches := make([]bool, len(lines))
-	p := g.cfg.Pattern
+func (g *Grep) runLines(src *bufio.Reader) error {
+	before := newRing(g.cfg.Before)
+	afterLeft := 0
 
-	var matcher func(string) bool
+	var off int64
+	var restart bool
+	for n := 1; ; n++ {
+		if restart {
+			n, off, afterLeft, restart = 1, 0, 0, false
+			before.drain()
+			g.out.restart()
+		}
 
-	if g.cfg.Fixed {
-		if g.cfg.IgnoreCase {
-			p = strings.ToLower(p)
-			matcher = func(s string) bool {
-				return strings.Contains(strings.ToLower(s), p)
-			}
-		} else {
-			matcher = func(s string) bool {
-				return strings.Contains(s, p)
+		limited := g.cfg.MaxCount > 0 && g.matches >= g.cfg.MaxCount
+		if limited && (g.quiet || afterLeft == 0) {
+			return nil
+		}
+
+		text, size, err := readLine(src)
+		if errors.Is(err, ErrRestart) {
+			// The restart comes after the cut off last line of the old
+			// content, if there is one.
+			restart = true
+			if size == 0 {
+				continue
 			}
+			err = nil
 		}
-	} else {
-		if g.cfg.IgnoreCase {
-			p = "(?i)" + p
+		if err == io.EOF {
+			return nil
 		}
-		re, err := regexp.Compile(p)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		matcher = func(s string) bool {
-			return re.MatchString(s)
+		l := line{num: n, off: off, text: text}
+		off += int64(size)
+
+		if limited {
+			// After -m matches only the trailing context is printed,
+			// whether the lines match or not.
+			if err := g.out.print(l, false); err != nil {
+				return err
+			}
+			afterLeft--
+			continue
 		}
-	}
 
-	for i, line := range lines {
-		matched := matcher(line)
+		matched, err := g.match.Match(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
 		if g.cfg.Invert {
 			matched = !matched
 		}
-		matches[i] = matched
-	}
-
-	return matches, nil
-}
-
-func (g *Grep) calculateOutputLines(matches []bool, totalLines int) []bool {
-	toOutput := make([]bool, totalLines)
-
-	for i := 0; i < totalLines; i++ {
-		if matches[i] {
-			toOutput[i] = true
+		if matched {
+			g.matches++
+		}
 
-			//before context
-			for j := i - g.cfg.Before; j < i; j++ {
-				if j >= 0 {
-					toOutput[j] = true
-				}
+		if g.quiet {
+			if matched && g.firstOnly() {
+				return nil
 			}
+			continue
+		}
 
-			//after context
-			for j := i + 1; j <= i+g.cfg.After; j++ {
-				if j < totalLines {
-					toOutput[j] = true
+		switch {
+		case matched:
+			for _, l := range before.drain() {
+				if err := g.out.print(l, false); err != nil {
+					return err
 				}
 			}
+			if err := g.out.print(l, true); err != nil {
+				return err
+			}
+			afterLeft = g.cfg.After
+		case afterLeft > 0:
+			if err := g.out.print(l, false); err != nil {
+				return err
+			}
+			afterLeft--
+		default:
+			before.push(l)
 		}
 	}
+}
 
-	return toOutput
+// output writes the lines selected by Run, as text or as JSON events.
+type output interface {
+	print(l line, matched bool) error
+	// restart starts a new group of lines, see ErrRestart.
+	restart()
 }
 
-func (g *Grep) outputLines(dst io.Writer, lines []string, toOutput []bool, matches []bool) {
-	lastOutputIndex := -1
-	sep := "--"
-	useSep := g.cfg.After > 0 || g.cfg.Before > 0
+// Matches returns the number of selected lines seen by Run. In modes that
+// stop at the first match it is at most one.
+func (g *Grep) Matches() int {
+	return g.matches
+}
 
-	for i := 0; i < len(lines); i++ {
-		if !toOutput[i] {
-			continue
-		}
+// summary prints the per-file result of the modes that do not print lines.
+// File names and separators are colored as in the lines.
+func (g *Grep) summary(dst io.Writer, binary bool) error {
+	colors := colorsOf(g.cfg)
+	name := colors.wrap(colors.File, g.name)
 
-		if useSep && lastOutputIndex != -1 && i > lastOutputIndex+1 {
-			fmt.Fprintln(dst, sep)
+	var err error
+	switch {
+	case g.cfg.Quiet:
+	case g.cfg.FilesWithMatches:
+		if g.matches > 0 {
+			_, err = fmt.Fprintln(dst, name)
 		}
-
-		prefix := ""
-		if g.cfg.LineNum {
-			if matches[i] {
-				prefix = fmt.Sprintf("%d:", i+1)
-			} else {
-				prefix = fmt.Sprintf("%d-", i+1)
-			}
+	case g.cfg.FilesWithoutMatch:
+		if g.matches == 0 {
+			_, err = fmt.Fprintln(dst, name)
+		}
+	case g.cfg.CountOnly:
+		if g.cfg.WithFilename {
+			_, err = fmt.Fprintf(dst, "%s%s%d\n", name, colors.wrap(colors.Sep, ":"), g.matches)
+		} else {
+			_, err = fmt.Fprintf(dst, "%d\n", g.matches)
 		}
+	case binary:
+		if g.matches > 0 {
+			_, err = fmt.Fprintf(dst, "Binary file %s matches\n", g.name)
+		}
+	}
+	return err
+}
+
+// readLine returns the next line without its "\n" or "\r\n" ending and
+// the number of bytes read, ending included. Lines may be of any length.
+func readLine(r *bufio.Reader) (string, int, error) {
+	s, err := r.ReadString('\n')
+	switch {
+	case err == io.EOF && s != "":
+		err = nil
+	case errors.Is(err, ErrRestart):
+		// The line read before the restart is returned with it.
+	case err != nil:
+		return "", 0, err
+	}
+
+	size := len(s)
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r"), size, err
+}
+
+// ring keeps the last size lines that were not printed yet.
+type ring struct {
+	lines []line
+	start int
+	n     int
+}
+
+func newRing(size int) *ring {
+	return &ring{lines: make([]line, size)}
+}
+
+func (r *ring) push(l line) {
+	if len(r.lines) == 0 {
+		return
+	}
+	if r.n < len(r.lines) {
+		r.lines[(r.start+r.n)%len(r.lines)] = l
+		r.n++
+		return
+	}
+	r.lines[r.start] = l
+	r.start = (r.start + 1) % len(r.lines)
+}
 
-		fmt.Fprintf(dst, "%s%s\n", prefix, lines[i])
-		lastOutputIndex = i
+// drain returns the buffered lines in input order and empties the ring.
+func (r *ring) drain() []line {
+	res := make([]line, 0, r.n)
+	for i := 0; i < r.n; i++ {
+		res = append(res, r.lines[(r.start+i)%len(r.lines)])
 	}
+	r.start, r.n = 0, 0
+	return res
 }
@@ -1,10 +1,19 @@
 package grep
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"wb-grep/internal/config"
+	"wb-grep/internal/pcre"
 )
 
 func TestGrep_Run(t *testing.T) {
@@ -144,6 +153,448 @@ func TestGrep_Run(t *testing.T) {
 			input:    "A\nB\nC\nD\nA",
 			expected: "A\nB\n--\nD\nA\n",
 		},
+		{
+			name: "Several patterns (-e)",
+			cfg: config.Config{
+				Patterns: []string{"^b", "pie$"},
+			},
+			input:    "apple\nbanana\napple pie",
+			expected: "banana\napple pie\n",
+		},
+		{
+			name: "No patterns from empty -f",
+			cfg: config.Config{
+				Patterns: []string{},
+			},
+			input:    "apple\nbanana",
+			expected: "",
+		},
+		{
+			name: "Several fixed strings",
+			cfg: config.Config{
+				Patterns: []string{"a.p", "ban", "pie"},
+				Fixed:    true,
+			},
+			input:    "apple\nbanana\na.p\ncherry",
+			expected: "banana\na.p\n",
+		},
+		{
+			name: "Fixed strings ignore case",
+			cfg: config.Config{
+				Patterns:   []string{"ПРИВЕТ", "hello"},
+				Fixed:      true,
+				IgnoreCase: true,
+			},
+			input:    "Привет, мир\nHELLO\nbye",
+			expected: "Привет, мир\nHELLO\n",
+		},
+		{
+			name: "Whole word regexp (-w)",
+			cfg: config.Config{
+				Pattern:    "foo|bar",
+				WordRegexp: true,
+			},
+			input:    "foobar\nfoo bar\nxfoo\nbar_\n(bar)",
+			expected: "foo bar\n(bar)\n",
+		},
+		{
+			name: "Whole word retries later match",
+			cfg: config.Config{
+				Pattern:    "foo",
+				WordRegexp: true,
+			},
+			input:    "foofoo foo\nfoofoo",
+			expected: "foofoo foo\n",
+		},
+		{
+			name: "Whole word fixed Cyrillic",
+			cfg: config.Config{
+				Patterns:   []string{"кот", "пёс"},
+				Fixed:      true,
+				WordRegexp: true,
+			},
+			input:    "котлета\nмой кот\nпёсик\nпёс.",
+			expected: "мой кот\nпёс.\n",
+		},
+		{
+			name: "Whole word fixed falls back to shorter pattern",
+			cfg: config.Config{
+				Patterns:     []string{"foo", "foo-b"},
+				Fixed:        true,
+				WordRegexp:   true,
+				OnlyMatching: true,
+			},
+			input:    "foo-bar\nfoo-b\nfoo-barfoo",
+			expected: "foo\nfoo-b\nfoo\n",
+		},
+		{
+			name: "Whole line regexp (-x)",
+			cfg: config.Config{
+				Patterns:   []string{"a|ab", "c+"},
+				LineRegexp: true,
+			},
+			input:    "ab\nabc\nccc\nxc",
+			expected: "ab\nccc\n",
+		},
+		{
+			name: "Whole line fixed",
+			cfg: config.Config{
+				Patterns:   []string{"ab", "abc"},
+				Fixed:      true,
+				LineRegexp: true,
+				IgnoreCase: true,
+			},
+			input:    "AB\nabcd\nABC\nxab",
+			expected: "AB\nABC\n",
+		},
+		{
+			name: "Only matching (-o)",
+			cfg: config.Config{
+				Pattern:      "a+",
+				OnlyMatching: true,
+				LineNum:      true,
+			},
+			input:    "banana\nxyz\naa b a",
+			expected: "1:a\n1:a\n1:a\n3:aa\n3:a\n",
+		},
+		{
+			name: "Only matching longest of alternatives",
+			cfg: config.Config{
+				Patterns:     []string{"foo", "foob", "x|xy"},
+				OnlyMatching: true,
+			},
+			input:    "foobar xyz",
+			expected: "foob\nxy\n",
+		},
+		{
+			name: "Only matching longest of basic alternatives",
+			cfg: config.Config{
+				Patterns:     []string{"a", "ab*"},
+				Basic:        true,
+				OnlyMatching: true,
+			},
+			input:    "abbc",
+			expected: "abb\n",
+		},
+		{
+			name: "Only matching fixed ignore case",
+			cfg: config.Config{
+				Patterns:     []string{"при", "вет"},
+				Fixed:        true,
+				IgnoreCase:   true,
+				OnlyMatching: true,
+			},
+			input:    "ПРИВЕТ мир",
+			expected: "ПРИ\nВЕТ\n",
+		},
+		{
+			name: "Only matching skips context",
+			cfg: config.Config{
+				Pattern:      "b",
+				OnlyMatching: true,
+				After:        1,
+			},
+			input:    "ab\nc\nd\nbb",
+			expected: "b\nb\nb\n",
+		},
+		{
+			name: "Byte offset (-b)",
+			cfg: config.Config{
+				Pattern:    "x",
+				ByteOffset: true,
+				Before:     1,
+			},
+			input:    "ab\r\nяx\nx",
+			expected: "0-ab\n4:яx\n8:x\n",
+		},
+		{
+			name: "Byte offset of matches (-o -b)",
+			cfg: config.Config{
+				Pattern:      "x",
+				Fixed:        true,
+				ByteOffset:   true,
+				OnlyMatching: true,
+			},
+			input:    "ab\nяx x",
+			expected: "5:x\n7:x\n",
+		},
+		{
+			name: "Column (--column)",
+			cfg: config.Config{
+				Pattern: "an",
+				Column:  true,
+				LineNum: true,
+				After:   1,
+			},
+			input:    "banana\nxyz",
+			expected: "1:2:banana\n2-xyz\n",
+		},
+		{
+			name: "Column of whole word",
+			cfg: config.Config{
+				Pattern:    "an",
+				Column:     true,
+				WordRegexp: true,
+			},
+			input:    "banana an",
+			expected: "8:banana an\n",
+		},
+		{
+			name: "Color",
+			cfg: config.Config{
+				Pattern:      "a",
+				Color:        true,
+				WithFilename: true,
+				LineNum:      true,
+			},
+			input: "bab",
+			expected: "\x1b[35m\x1b[K" + StdinName + "\x1b[m\x1b[K\x1b[36m\x1b[K:\x1b[m\x1b[K" +
+				"\x1b[32m\x1b[K1\x1b[m\x1b[K\x1b[36m\x1b[K:\x1b[m\x1b[K" +
+				"b\x1b[01;31m\x1b[Ka\x1b[m\x1b[Kb\n",
+		},
+		{
+			name: "Color from GREP_COLORS",
+			cfg: config.Config{
+				Patterns:   []string{"a", "c"},
+				Fixed:      true,
+				Color:      true,
+				GrepColors: "ms=04:ne",
+			},
+			input:    "abc",
+			expected: "\x1b[04ma\x1b[mb\x1b[04mc\x1b[m\n",
+		},
+		{
+			name: "Color of matches in inverted context",
+			cfg: config.Config{
+				Pattern:    "a",
+				Invert:     true,
+				Color:      true,
+				GrepColors: "mc=33:sl=1:se=:ne",
+				Before:     1,
+			},
+			input:    "ab\nc",
+			expected: "\x1b[33ma\x1b[mb\n\x1b[1mc\x1b[m\n",
+		},
+		{
+			name: "Color of whole selected and context lines",
+			cfg: config.Config{
+				Pattern:    "a",
+				Color:      true,
+				GrepColors: "sl=1:cx=2",
+				After:      1,
+			},
+			input: "babxa\nzz\n",
+			expected: "\x1b[1m\x1b[Kb\x1b[01;31m\x1b[Ka\x1b[m\x1b[K\x1b[1m\x1b[Kbx\x1b[01;31m\x1b[Ka\x1b[m\x1b[K\n" +
+				"\x1b[2m\x1b[Kzz\x1b[m\x1b[K\n",
+		},
+		{
+			name: "Color of lines swapped by rv with -v",
+			cfg: config.Config{
+				Pattern:    "c",
+				Invert:     true,
+				Color:      true,
+				GrepColors: "sl=1:cx=2:rv:ne",
+				After:      1,
+			},
+			input:    "ab\nc\n",
+			expected: "\x1b[2mab\x1b[m\n\x1b[1m\x1b[01;31mc\x1b[m\n",
+		},
+		{
+			name: "Color of lines kept by rv without -v",
+			cfg: config.Config{
+				Pattern:    "c",
+				Color:      true,
+				GrepColors: "sl=1:cx=2:rv:ne",
+				After:      1,
+			},
+			input:    "c\nab\n",
+			expected: "\x1b[1m\x1b[01;31mc\x1b[m\n\x1b[2mab\x1b[m\n",
+		},
+		{
+			name: "Color of selected line without match color",
+			cfg: config.Config{
+				Pattern:    "a",
+				Color:      true,
+				GrepColors: "sl=1:ms=",
+			},
+			input:    "ab\n",
+			expected: "\x1b[1m\x1b[Kab\x1b[m\x1b[K\n",
+		},
+		{
+			name: "Color of file names (-l)",
+			cfg: config.Config{
+				Pattern:          "a",
+				Color:            true,
+				FilesWithMatches: true,
+			},
+			input:    "a\n",
+			expected: "\x1b[35m\x1b[K" + StdinName + "\x1b[m\x1b[K\n",
+		},
+		{
+			name: "Color of file names (-L)",
+			cfg: config.Config{
+				Pattern:           "a",
+				Color:             true,
+				GrepColors:        "fn=33:ne",
+				FilesWithoutMatch: true,
+			},
+			input:    "b\n",
+			expected: "\x1b[33m" + StdinName + "\x1b[m\n",
+		},
+		{
+			name: "Color of counts with file names (-c)",
+			cfg: config.Config{
+				Pattern:      "a",
+				Color:        true,
+				CountOnly:    true,
+				WithFilename: true,
+			},
+			input: "a\n",
+			expected: "\x1b[35m\x1b[K" + StdinName + "\x1b[m\x1b[K" +
+				"\x1b[36m\x1b[K:\x1b[m\x1b[K1\n",
+		},
+		{
+			name: "Max count (-m)",
+			cfg: config.Config{
+				Pattern:  "a",
+				MaxCount: 2,
+				LineNum:  true,
+			},
+			input:    "a1\nb\na2\na3\n",
+			expected: "1:a1\n3:a2\n",
+		},
+		{
+			name: "Max count prints trailing context",
+			cfg: config.Config{
+				Pattern:  "a",
+				MaxCount: 1,
+				After:    2,
+				LineNum:  true,
+			},
+			input:    "x\na1\na2\nb\nc\n",
+			expected: "2:a1\n3-a2\n4-b\n",
+		},
+		{
+			name: "Max count with count and invert",
+			cfg: config.Config{
+				Pattern:   "a",
+				MaxCount:  2,
+				CountOnly: true,
+				Invert:    true,
+			},
+			input:    "b\nc\nd\n",
+			expected: "2\n",
+		},
+		{
+			name: "Quiet (-q)",
+			cfg: config.Config{
+				Pattern:      "a",
+				Quiet:        true,
+				WithFilename: true,
+			},
+			input:    "a\nb\n",
+			expected: "",
+		},
+		{
+			name: "JSON field",
+			cfg: config.Config{
+				Pattern:   "err",
+				JSONField: "level",
+			},
+			input:    `{"level":"error","msg":"disk"}` + "\n" + `{"level":"info","msg":"error"}` + "\nnot json\n",
+			expected: `{"level":"error","msg":"disk"}` + "\n",
+		},
+		{
+			name: "JSON nested field and array index",
+			cfg: config.Config{
+				Pattern:      "[0-9]+",
+				JSONField:    "req.ids.1",
+				OnlyMatching: true,
+				ByteOffset:   true,
+			},
+			input:    `{"req": {"ids": [7, 42]}}` + "\n" + `{"req": {"ids": [7]}}`,
+			expected: "20:42\n",
+		},
+		{
+			name: "JSON field whole value",
+			cfg: config.Config{
+				Pattern:    `a"b`,
+				Fixed:      true,
+				LineRegexp: true,
+				JSONField:  "s",
+				Column:     true,
+			},
+			input:    `{"n": null, "s": "a\"b"}` + "\n" + `{"s": "a\"bc"}`,
+			expected: `18:{"n": null, "s": "a\"b"}` + "\n",
+		},
+		{
+			name: "JSON field objects match their JSON text",
+			cfg: config.Config{
+				Pattern:   `"id":1\b`,
+				JSONField: "user",
+			},
+			input:    `{"user":{"id":1}}` + "\n" + `{"user":{"id":12}}` + "\n" + `{"id":1}`,
+			expected: `{"user":{"id":1}}` + "\n",
+		},
+		{
+			name: "Perl backreference (-P)",
+			cfg: config.Config{
+				Pattern: `(\w)\1`,
+				Perl:    true,
+			},
+			input:    "abc\nabbc\nxyz",
+			expected: "abbc\n",
+		},
+		{
+			name: "Perl lookaround",
+			cfg: config.Config{
+				Pattern:      `(?<=user=)\w+(?= failed)`,
+				Perl:         true,
+				OnlyMatching: true,
+			},
+			input:    "user=bob ok\nuser=alice failed\n",
+			expected: "alice\n",
+		},
+		{
+			name: "Perl several patterns keep their groups",
+			cfg: config.Config{
+				Patterns:     []string{`(a)\1`, `(b)\1`},
+				Perl:         true,
+				OnlyMatching: true,
+			},
+			input:    "xbbyaa",
+			expected: "bb\naa\n",
+		},
+		{
+			name: "Perl whole word and ignore case",
+			cfg: config.Config{
+				Pattern:    `и\w`,
+				Perl:       true,
+				WordRegexp: true,
+				IgnoreCase: true,
+			},
+			input:    "или\nИЗ дома",
+			expected: "ИЗ дома\n",
+		},
+		{
+			name: "Perl whole line",
+			cfg: config.Config{
+				Pattern:    `a|ab`,
+				Perl:       true,
+				LineRegexp: true,
+			},
+			input:    "ab\nabc",
+			expected: "ab\n",
+		},
+		{
+			name: "Basic regexp (-G)",
+			cfg: config.Config{
+				Pattern: `a\(b\|c\)\{2\}+`,
+				Basic:   true,
+			},
+			input:    "abc+\nabc\nacb+",
+			expected: "abc+\nacb+\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -163,3 +614,334 @@ func TestGrep_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestGrep_RunStreams(t *testing.T) {
+	pr, pw := io.Pipe()
+	outR, outW := io.Pipe()
+
+	cfg := config.Config{Pattern: "apple", After: 1}
+	done := make(chan error, 1)
+	go func() {
+		done <- New(&cfg, pr).Run(outW)
+		outW.Close()
+	}()
+
+	out := bufio.NewReader(outR)
+	for _, tc := range []struct{ in, want string }{
+		{"apple\n", "apple\n"},
+		{"banana\n", "banana\n"},
+		{"cherry\napple pie\n", "--\n"},
+	} {
+		if _, err := io.WriteString(pw, tc.in); err != nil {
+			t.Fatal(err)
+		}
+		got, err := out.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("got %q before end of input, want %q", got, tc.want)
+		}
+	}
+
+	pw.Close()
+	rest, _ := io.ReadAll(out)
+	if string(rest) != "apple pie\n" {
+		t.Errorf("rest = %q, want %q", rest, "apple pie\n")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+// TestGrep_RunContextRandom compares streaming output with a reference that
+// marks lines to print over the whole input, as grep did before streaming.
+func TestGrep_RunContextRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		lines := make([]string, rnd.Intn(30))
+		for j := range lines {
+			lines[j] = string(rune('a' + rnd.Intn(4)))
+		}
+		cfg := config.Config{
+			Pattern: "a",
+			Before:  rnd.Intn(4),
+			After:   rnd.Intn(4),
+			Invert:  rnd.Intn(4) == 0,
+			LineNum: rnd.Intn(2) == 0,
+		}
+
+		want := referenceGrep(&cfg, lines)
+
+		var dst bytes.Buffer
+		if err := New(&cfg, strings.NewReader(strings.Join(lines, "\n"))).Run(&dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst.String() != want {
+			t.Fatalf("cfg %+v, input %q:\ngot  %q\nwant %q", cfg, lines, dst.String(), want)
+		}
+	}
+}
+
+func referenceGrep(cfg *config.Config, lines []string) string {
+	matches := make([]bool, len(lines))
+	toOutput := make([]bool, len(lines))
+	for i, l := range lines {
+		matches[i] = strings.Contains(l, cfg.Pattern) != cfg.Invert
+	}
+	for i := range lines {
+		if !matches[i] {
+			continue
+		}
+		for j := max(0, i-cfg.Before); j <= min(len(lines)-1, i+cfg.After); j++ {
+			toOutput[j] = true
+		}
+	}
+
+	var sb strings.Builder
+	last := -1
+	for i, l := range lines {
+		if !toOutput[i] {
+			continue
+		}
+		if (cfg.After > 0 || cfg.Before > 0) && last != -1 && i > last+1 {
+			sb.WriteString("--\n")
+		}
+		if cfg.LineNum {
+			sep := "-"
+			if matches[i] {
+				sep = ":"
+			}
+			fmt.Fprintf(&sb, "%d%s", i+1, sep)
+		}
+		sb.WriteString(l + "\n")
+		last = i
+	}
+	return sb.String()
+}
+
+func TestGrep_RunJSON(t *testing.T) {
+	cfg := config.Config{Pattern: "a+", JSON: true, Before: 1}
+	input := "xyz\nbaab a\nnone\n"
+
+	var dst bytes.Buffer
+	if err := NewFile(&cfg, "f.txt", strings.NewReader(input), nil).Run(&dst); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []string{
+		`{"type":"begin","data":{"path":{"text":"f.txt"}}}`,
+		`{"type":"context","data":{"path":{"text":"f.txt"},"lines":{"text":"xyz\n"},"line_number":1,"absolute_offset":0,"submatches":[]}}`,
+		`{"type":"match","data":{"path":{"text":"f.txt"},"lines":{"text":"baab a\n"},"line_number":2,"absolute_offset":4,` +
+			`"submatches":[{"match":{"text":"aa"},"start":1,"end":3},{"match":{"text":"a"},"start":5,"end":6}]}}`,
+		`{"type":"end","data":{"path":{"text":"f.txt"},"binary_offset":null,"stats":{"matched_lines":1,"matches":2}}}`,
+	}
+	if got := strings.Split(strings.TrimSuffix(dst.String(), "\n"), "\n"); !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Files without output have no events, binary files only begin and end.
+	dst.Reset()
+	NewFile(&cfg, "g", strings.NewReader("xyz\n"), nil).Run(&dst)
+	if dst.Len() != 0 {
+		t.Errorf("no match: got %q, want no events", dst.String())
+	}
+
+	dst.Reset()
+	NewFile(&cfg, "bin", strings.NewReader("ab\x00\n\xff\n"), nil).Run(&dst)
+	want = []string{
+		`{"type":"begin","data":{"path":{"text":"bin"}}}`,
+		`{"type":"end","data":{"path":{"text":"bin"},"binary_offset":2,"stats":{"matched_lines":1,"matches":0}}}`,
+	}
+	if got := strings.Split(strings.TrimSuffix(dst.String(), "\n"), "\n"); !slices.Equal(got, want) {
+		t.Errorf("binary: got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Invalid UTF-8 is base64 encoded.
+	dst.Reset()
+	cfg = config.Config{Pattern: "a", JSON: true, Text: true}
+	NewFile(&cfg, "raw", strings.NewReader("a\xff\n"), nil).Run(&dst)
+	if !strings.Contains(dst.String(), `"lines":{"bytes":"Yf8K"}`) {
+		t.Errorf("invalid UTF-8: got %s", dst.String())
+	}
+}
+
+func TestTranslateBRE(t *testing.T) {
+	tests := []struct {
+		bre  string
+		want string
+	}{
+		{`a.b`, `a.b`},
+		{`a+b?`, `a\+b\?`},
+		{`a\+b\?`, `a+b?`},
+		{`\(ab\)*`, `(ab)*`},
+		{`*a`, `\*a`},
+		{`^*a`, `^\*a`},
+		{`\(*a\)`, `(\*a)`},
+		{`a\|*b`, `a|\*b`},
+		{`a^b$c`, `a\^b\$c`},
+		{`^a$`, `^a$`},
+		{`\(a$\)`, `(a$)`},
+		{`a\{2,3\}`, `a{2,3}`},
+		{`(a){1}|`, `\(a\)\{1\}\|`},
+		{`[\]]`, `[\\]]`},
+		{`[]a]`, `[]a]`},
+		{`[[:alpha:]]x`, `[[:alpha:]]x`},
+		{`\<w\>`, `\bw\b`},
+		{`\.\*`, `\.\*`},
+		{`при\вет`, `привет`},
+	}
+
+	for _, tt := range tests {
+		got, err := translateBRE(tt.bre)
+		if err != nil {
+			t.Errorf("translateBRE(%q) error = %v", tt.bre, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("translateBRE(%q) = %q, want %q", tt.bre, got, tt.want)
+		}
+	}
+
+	for _, bre := range []string{`a\`, `[a`, `a\{2`, `\(a\)\1`} {
+		if _, err := translateBRE(bre); err == nil {
+			t.Errorf("translateBRE(%q) error = nil, want an error", bre)
+		}
+	}
+}
+
+func TestGrep_RunStepLimit(t *testing.T) {
+	cfg := config.Config{Pattern: `(a+)+$`, Perl: true}
+	input := "ok\n" + strings.Repeat("a", 40) + "b\n"
+
+	err := New(&cfg, strings.NewReader(input)).Run(io.Discard)
+	if !errors.Is(err, pcre.ErrStepLimit) {
+		t.Errorf("Run() error = %v, want ErrStepLimit", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Run() error = %v, want the line number", err)
+	}
+}
+
+func TestParseColors(t *testing.T) {
+	got := ParseColors("mt=01;32:fn=:ln=33:xx=1:rv:ne")
+	want := DefaultColors
+	want.Match, want.ContextMatch = "01;32", "01;32"
+	want.File = ""
+	want.Line = "33"
+	want.Reverse, want.NoClear = true, true
+	if got != want {
+		t.Errorf("ParseColors() = %+v, want %+v", got, want)
+	}
+}
+
+// TestGrep_RunFixedRandom compares the fixed string fast path, over reads
+// of any size, with the line by line search of the
+// same pattern as a regular expression.
+func TestGrep_RunFixedRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	pick := func(alphabet string, n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+
+	for i := 0; i < 1000; i++ {
+		input := pick("aab \n", rnd.Intn(60))
+		switch {
+		case i%100 == 0:
+			// Lines longer than the chunks of runFixed.
+			input += strings.Repeat("b", 300*1024) + "ab\n" + input
+		case i%10 == 0:
+			input += "\r\n" + input + "\x00"
+		}
+		cfg := config.Config{
+			Pattern:    pick("ab", 1+rnd.Intn(3)),
+			LineNum:    rnd.Intn(2) == 0,
+			ByteOffset: rnd.Intn(4) == 0,
+			WordRegexp: rnd.Intn(4) == 0,
+			LineRegexp: rnd.Intn(6) == 0,
+			MaxCount:   rnd.Intn(3),
+		}
+		switch rnd.Intn(8) {
+		case 0:
+			cfg.CountOnly = true
+		case 1:
+			cfg.OnlyMatching = true
+		case 2:
+			cfg.FilesWithMatches = true
+		case 3:
+			cfg.JSON = true
+		}
+
+		ref := cfg
+		ref.Pattern = regexp.QuoteMeta(cfg.Pattern)
+		var want bytes.Buffer
+		if err := New(&ref, strings.NewReader(input)).Run(&want); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg.Fixed = true
+		for _, src := range []io.Reader{
+			strings.NewReader(input),
+			iotest.HalfReader(strings.NewReader(input)),
+		} {
+			g := New(&cfg, src)
+			if !g.fixed() {
+				t.Fatalf("cfg %+v does not take the fast path", cfg)
+			}
+			var got bytes.Buffer
+			if err := g.Run(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Fatalf("cfg %+v, input %.200q, source %T:\ngot  %.200q\nwant %.200q", cfg, input, src, got.String(), want.String())
+			}
+		}
+	}
+}
+
+func benchmarkInput() string {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 16<<20; i++ {
+		fmt.Fprintf(&sb, "2024-05-01T12:00:%02d INFO request id=%d path=/api/items duration=%dms\n", i%60, i, i%1000)
+		if i%5000 == 0 {
+			sb.WriteString("2024-05-01T12:00:00 ERROR upstream timeout\n")
+		}
+	}
+	return sb.String()
+}
+
+// BenchmarkGrep_Fixed compares the fast path of -F with the line by line
+// search.
+func BenchmarkGrep_Fixed(b *testing.B) {
+	input := benchmarkInput()
+	cfg := config.Config{Pattern: "timeout", Fixed: true, LineNum: true}
+
+	b.Run("fast", func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		for range b.N {
+			if err := New(&cfg, strings.NewReader(input)).Run(io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("lines", func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		for range b.N {
+			g := New(&cfg, nil)
+			m, err := NewMatcher(&cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			g.match = m
+			g.out = newPrinter(&cfg, io.Discard, g.name, m)
+			if err := g.runLines(bufio.NewReaderSize(strings.NewReader(input), 64*1024)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
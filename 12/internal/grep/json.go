@@ -0,0 +1,146 @@
+package grep
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// JSON events of --json, one per line, in the format of ripgrep: "begin"
+// before the first line of a file, "match" and "context" for lines and
+// "end" with the statistics of the file.
+
+type jsonEvent struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// jsonText holds valid UTF-8 as text and anything else base64 encoded.
+type jsonText struct {
+	Text  *string `json:"text,omitempty"`
+	Bytes *string `json:"bytes,omitempty"`
+}
+
+func newJSONText(s string) jsonText {
+	if utf8.ValidString(s) {
+		return jsonText{Text: &s}
+	}
+	b := base64.StdEncoding.EncodeToString([]byte(s))
+	return jsonText{Bytes: &b}
+}
+
+type jsonBegin struct {
+	Path jsonText `json:"path"`
+}
+
+type jsonLine struct {
+	Path           jsonText       `json:"path"`
+	Lines          jsonText       `json:"lines"`
+	LineNumber     int            `json:"line_number"`
+	AbsoluteOffset int64          `json:"absolute_offset"`
+	Submatches     []jsonSubmatch `json:"submatches"`
+}
+
+type jsonSubmatch struct {
+	Match jsonText `json:"match"`
+	Start int      `json:"start"`
+	End   int      `json:"end"`
+}
+
+type jsonEnd struct {
+	Path         jsonText  `json:"path"`
+	BinaryOffset *int64    `json:"binary_offset"`
+	Stats        jsonStats `json:"stats"`
+}
+
+type jsonStats struct {
+	MatchedLines int `json:"matched_lines"`
+	Matches      int `json:"matches"`
+}
+
+// jsonPrinter writes lines as JSON events. The begin event is written with
+// the first line, so files without output produce no events.
+type jsonPrinter struct {
+	dst     io.Writer
+	name    string
+	match   Matcher
+	invert  bool
+	begun   bool
+	matches int
+}
+
+func (p *jsonPrinter) print(l line, matched bool) error {
+	if err := p.begin(); err != nil {
+		return err
+	}
+
+	typ := "context"
+	if matched {
+		typ = "match"
+	}
+	data := jsonLine{
+		Path:           newJSONText(p.name),
+		Lines:          newJSONText(l.text + "\n"),
+		LineNumber:     l.num,
+		AbsoluteOffset: l.off,
+		Submatches:     []jsonSubmatch{},
+	}
+
+	// Inverted matches have nothing to point at.
+	if matched && !p.invert {
+		spans, err := p.match.FindAll(l.text, -1)
+		if err != nil {
+			return err
+		}
+		for _, s := range spans {
+			data.Submatches = append(data.Submatches, jsonSubmatch{
+				Match: newJSONText(l.text[s[0]:s[1]]),
+				Start: s[0],
+				End:   s[1],
+			})
+		}
+		p.matches += len(spans)
+	}
+	return writeEvent(p.dst, typ, data)
+}
+
+// restart does nothing, JSON events carry their own line numbers.
+func (p *jsonPrinter) restart() {}
+
+func (p *jsonPrinter) begin() error {
+	if p.begun {
+		return nil
+	}
+	p.begun = true
+	return writeEvent(p.dst, "begin", jsonBegin{Path: newJSONText(p.name)})
+}
+
+// end writes the end event of a file that had output. For binary files,
+// which have no line events, binaryOffset is the offset of the first NUL
+// byte and the events are written if there was a match.
+func (p *jsonPrinter) end(matchedLines int, binaryOffset *int64) error {
+	if binaryOffset != nil && matchedLines > 0 {
+		if err := p.begin(); err != nil {
+			return err
+		}
+	}
+	if !p.begun {
+		return nil
+	}
+
+	return writeEvent(p.dst, "end", jsonEnd{
+		Path:         newJSONText(p.name),
+		BinaryOffset: binaryOffset,
+		Stats:        jsonStats{MatchedLines: matchedLines, Matches: p.matches},
+	})
+}
+
+func writeEvent(dst io.Writer, typ string, data any) error {
+	b, err := json.Marshal(jsonEvent{Type: typ, Data: data})
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,115 @@
+package grep
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// fieldMatcher matches the value of a field of JSON lines for --json-field.
+// Strings are matched by their decoded text, other values by their JSON
+// text. Lines that are not JSON or lack the field do not match.
+type fieldMatcher struct {
+	inner Matcher
+	path  []string
+}
+
+func newFieldMatcher(inner Matcher, field string) *fieldMatcher {
+	return &fieldMatcher{inner: inner, path: strings.Split(field, ".")}
+}
+
+func (m *fieldMatcher) Match(s string) (bool, error) {
+	v, ok := m.value(s)
+	if !ok {
+		return false, nil
+	}
+	return m.inner.Match(v.text)
+}
+
+// FindAll returns the matches in the field value as offsets in the line.
+// When a string has escapes its text does not appear in the line as is,
+// and the whole value is reported as a single match instead.
+func (m *fieldMatcher) FindAll(s string, n int) ([][]int, error) {
+	v, ok := m.value(s)
+	if !ok {
+		return nil, nil
+	}
+	spans, err := m.inner.FindAll(v.text, n)
+	if err != nil || len(spans) == 0 {
+		return nil, err
+	}
+
+	if !v.exact {
+		return [][]int{{v.start, v.end}}, nil
+	}
+	for _, sp := range spans {
+		sp[0] += v.off
+		sp[1] += v.off
+	}
+	return spans, nil
+}
+
+// fieldValue is the value of the field in a line.
+type fieldValue struct {
+	text       string
+	start, end int  // the raw JSON value in the line
+	off        int  // the offset of text in the line, if exact
+	exact      bool // text appears in the line as is
+}
+
+func (m *fieldMatcher) value(s string) (fieldValue, bool) {
+	raw, start, ok := lookup([]byte(s), 0, m.path)
+	if !ok || string(raw) == "null" {
+		return fieldValue{}, false
+	}
+
+	v := fieldValue{text: string(raw), start: start, end: start + len(raw), off: start, exact: true}
+	if raw[0] == '"' {
+		if err := json.Unmarshal(raw, &v.text); err != nil {
+			return fieldValue{}, false
+		}
+		v.off = start + 1
+		v.exact = len(v.text) == len(raw)-2
+	}
+	return v, true
+}
+
+// lookup finds the value at path in the JSON value raw, which starts at
+// offset off of the line, and returns it with its offset.
+func lookup(raw []byte, off int, path []string) ([]byte, int, bool) {
+	if len(path) == 0 {
+		return raw, off, true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, 0, false
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok || delim != '{' && delim != '[' {
+		return nil, 0, false
+	}
+
+	for i := 0; dec.More(); i++ {
+		key := strconv.Itoa(i)
+		if delim == '{' {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, 0, false
+			}
+			key, _ = tok.(string)
+		}
+
+		var child json.RawMessage
+		if err := dec.Decode(&child); err != nil {
+			return nil, 0, false
+		}
+		if key == path[0] {
+			end := int(dec.InputOffset())
+			return lookup(child, off+end-len(child), path[1:])
+		}
+	}
+	return nil, 0, false
+}
@@ -0,0 +1,294 @@
+package grep
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"wb-grep/internal/ahocorasick"
+	"wb-grep/internal/config"
+)
+
+// wordClass matches a rune that is not part of a word for -w.
+const wordClass = `[^\p{L}\p{N}_]`
+
+// Matcher finds the patterns of a configuration in lines. Engines that
+// can give up on a line, like the backtracking one of -P, return an error.
+type Matcher interface {
+	// Match reports whether the line contains a match.
+	Match(s string) (bool, error)
+	// FindAll returns up to n non-overlapping matches in s as pairs of
+	// byte offsets. A negative n means all matches.
+	FindAll(s string, n int) ([][]int, error)
+}
+
+// NewMatcher compiles the patterns of cfg for the engine it selects: fixed
+// strings with -F, the backtracking engine with -P and RE2 otherwise, with
+// -G patterns translated from basic regular expressions first. With
+// --json-field the patterns are matched against a field of JSON lines.
+func NewMatcher(cfg *config.Config) (Matcher, error) {
+	m, err := newMatcher(cfg)
+	if err != nil || cfg.JSONField == "" {
+		return m, err
+	}
+	return newFieldMatcher(m, cfg.JSONField), nil
+}
+
+func newMatcher(cfg *config.Config) (Matcher, error) {
+	patterns := cfg.PatternList()
+	if len(patterns) == 0 {
+		return &matcher{
+			match: func(string) bool { return false },
+			find:  noFinder{},
+		}, nil
+	}
+
+	if cfg.Perl && !cfg.Fixed {
+		return newPerlMatcher(cfg, patterns)
+	}
+	if cfg.Basic && !cfg.Fixed {
+		ext := make([]string, len(patterns))
+		for i, p := range patterns {
+			var err error
+			if ext[i], err = translateBRE(p); err != nil {
+				return nil, err
+			}
+		}
+		patterns = ext
+	}
+
+	f, err := newFinder(cfg, patterns)
+	if err != nil {
+		return nil, err
+	}
+	m := &matcher{
+		find: f,
+		match: func(s string) bool {
+			return len(f.findAll(s, 1)) > 0
+		},
+	}
+
+	// Plain searches have cheaper ways to tell whether a line matches.
+	switch f := f.(type) {
+	case regexpFinder:
+		m.match = f.re.MatchString
+	case *fixedFinder:
+		if !f.word && !f.line && len(patterns) == 1 && !cfg.IgnoreCase {
+			p := patterns[0]
+			m.match = func(s string) bool {
+				return strings.Contains(s, p)
+			}
+		}
+	}
+	return m, nil
+}
+
+// finder returns up to n non-overlapping matches in s, left to right, as
+// pairs of byte offsets. A negative n means all matches.
+type finder interface {
+	findAll(s string, n int) [][]int
+}
+
+// matcher is the Matcher of fixed strings and RE2 expressions, which
+// always finish.
+type matcher struct {
+	match func(string) bool
+	find  finder
+}
+
+func (m *matcher) Match(s string) (bool, error) {
+	return m.match(s), nil
+}
+
+func (m *matcher) FindAll(s string, n int) ([][]int, error) {
+	return m.find.findAll(s, n), nil
+}
+
+func newFinder(cfg *config.Config, patterns []string) (finder, error) {
+	if cfg.Fixed {
+		f := &fixedFinder{word: cfg.WordRegexp && !cfg.LineRegexp, line: cfg.LineRegexp}
+		if len(patterns) == 1 && !cfg.IgnoreCase {
+			p := patterns[0]
+			f.find = func(s string, pos int) (int, int) {
+				i := strings.Index(s[pos:], p)
+				if i < 0 {
+					return -1, -1
+				}
+				return pos + i, pos + i + len(p)
+			}
+		} else {
+			a := ahocorasick.New(patterns, cfg.IgnoreCase)
+			f.find, f.ends = a.Find, a.Ends
+		}
+		return f, nil
+	}
+
+	re, err := compileRegexp(cfg, patterns)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.WordRegexp && !cfg.LineRegexp {
+		return &wordRegexpFinder{re: re}, nil
+	}
+	return regexpFinder{re: re}, nil
+}
+
+// compileRegexp joins the patterns into one alternation. For -x it is
+// anchored to the whole line, for -w it is surrounded by non-word runes,
+// with the pattern itself in the first group. Matches are leftmost-longest
+// as in POSIX and GNU grep, not leftmost-first as usual in RE2.
+func compileRegexp(cfg *config.Config, patterns []string) (*regexp.Regexp, error) {
+	alts := make([]string, len(patterns))
+	for i, p := range patterns {
+		alts[i] = "(?:" + p + ")"
+	}
+	p := strings.Join(alts, "|")
+
+	switch {
+	case cfg.LineRegexp:
+		p = "^(?:" + p + ")$"
+	case cfg.WordRegexp:
+		p = "(?:^|" + wordClass + ")(" + p + ")(?:" + wordClass + "|$)"
+	}
+	if cfg.IgnoreCase {
+		p = "(?i)" + p
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	re.Longest()
+	return re, nil
+}
+
+type noFinder struct{}
+
+func (noFinder) findAll(string, int) [][]int { return nil }
+
+type regexpFinder struct {
+	re *regexp.Regexp
+}
+
+func (f regexpFinder) findAll(s string, n int) [][]int {
+	return f.re.FindAllStringIndex(s, n)
+}
+
+// wordRegexpFinder finds matches of a -w regexp. The regexp consumes the
+// runes around the word, so the search is restarted right after every
+// match, and the boundaries are checked again on the whole line.
+type wordRegexpFinder struct {
+	re *regexp.Regexp
+}
+
+func (f *wordRegexpFinder) findAll(s string, n int) [][]int {
+	var res [][]int
+	for pos := 0; pos <= len(s) && (n < 0 || len(res) < n); {
+		loc := f.re.FindStringSubmatchIndex(s[pos:])
+		if loc == nil {
+			break
+		}
+
+		start, end := pos+loc[2], pos+loc[3]
+		if !isWord(s, start, end) {
+			pos = start + runeLen(s, start)
+			continue
+		}
+
+		res = append(res, []int{start, end})
+		pos = end
+		if end == start {
+			pos += runeLen(s, end)
+		}
+	}
+	return res
+}
+
+// fixedFinder finds fixed strings with find, which returns the leftmost
+// match starting at or after pos. With several patterns, ends returns the
+// ends of all matches at a start, so that -w can fall back to a shorter
+// pattern when the longest one is not a whole word.
+type fixedFinder struct {
+	find func(s string, pos int) (int, int)
+	ends func(s string, start int) []int
+	word bool
+	line bool
+}
+
+func (f *fixedFinder) findAll(s string, n int) [][]int {
+	if f.line {
+		// find returns the longest of the leftmost matches, so a pattern
+		// equal to the line is found if there is one.
+		if start, end := f.find(s, 0); start == 0 && end == len(s) && n != 0 {
+			return [][]int{{0, len(s)}}
+		}
+		return nil
+	}
+
+	var res [][]int
+	for pos := 0; pos <= len(s) && (n < 0 || len(res) < n); {
+		start, end := f.find(s, pos)
+		if start < 0 {
+			break
+		}
+
+		if f.word && !isWord(s, start, end) {
+			if end = f.wordEnd(s, start); end < 0 {
+				pos = start + runeLen(s, start)
+				continue
+			}
+		}
+
+		res = append(res, []int{start, end})
+		pos = end
+		if end == start {
+			pos += runeLen(s, end)
+		}
+	}
+	return res
+}
+
+// wordEnd returns the end of the longest match at start that is a whole
+// word, or -1 if there is none.
+func (f *fixedFinder) wordEnd(s string, start int) int {
+	if f.ends == nil {
+		return -1
+	}
+	ends := f.ends(s, start)
+	for i := len(ends) - 1; i >= 0; i-- {
+		if isWord(s, start, ends[i]) {
+			return ends[i]
+		}
+	}
+	return -1
+}
+
+// isWord reports whether s[start:end] is not preceded or followed by a word rune.
+func isWord(s string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(s[:start])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	if end < len(s) {
+		r, _ := utf8.DecodeRuneInString(s[end:])
+		if isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// runeLen returns the length of the rune at s[i:], at least 1 so that
+// searches always move forward.
+func runeLen(s string, i int) int {
+	if i >= len(s) {
+		return 1
+	}
+	_, w := utf8.DecodeRuneInString(s[i:])
+	return w
+}
@@ -0,0 +1,97 @@
+package grep
+
+import (
+	"wb-grep/internal/config"
+	"wb-grep/internal/pcre"
+)
+
+// wordRune matches a rune that is part of a word for -w.
+const wordRune = `[\p{L}\p{N}_]`
+
+// perlMatcher matches -P patterns with the backtracking engine. Each
+// pattern is compiled on its own, so that backreferences refer to the
+// groups of their own pattern.
+type perlMatcher struct {
+	res []*pcre.Regexp
+}
+
+// newPerlMatcher compiles the patterns. With lookaround -w needs no
+// retries: the pattern just must not touch word runes on either side.
+func newPerlMatcher(cfg *config.Config, patterns []string) (Matcher, error) {
+	m := &perlMatcher{}
+	for _, p := range patterns {
+		switch {
+		case cfg.LineRegexp:
+			p = "^(?:" + p + ")$"
+		case cfg.WordRegexp:
+			p = "(?<!" + wordRune + ")(?:" + p + ")(?!" + wordRune + ")"
+		}
+		if cfg.IgnoreCase {
+			p = "(?i)" + p
+		}
+
+		re, err := pcre.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		m.res = append(m.res, re)
+	}
+	return m, nil
+}
+
+func (m *perlMatcher) Match(s string) (bool, error) {
+	for _, re := range m.res {
+		if ok, err := re.MatchString(s); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+func (m *perlMatcher) FindAll(s string, n int) ([][]int, error) {
+	if len(m.res) == 1 {
+		return m.res[0].FindAllStringIndex(s, n)
+	}
+
+	var res [][]int
+	prevEnd := -1
+	for pos := 0; pos <= len(s) && (n < 0 || len(res) < n); {
+		loc, err := m.find(s, pos)
+		if err != nil {
+			return nil, err
+		}
+		if loc == nil {
+			break
+		}
+
+		start, end := loc[0], loc[1]
+		if start == end && start == prevEnd {
+			pos = start + runeLen(s, start)
+			continue
+		}
+
+		res = append(res, []int{start, end})
+		prevEnd = end
+		pos = end
+		if start == end {
+			pos += runeLen(s, end)
+		}
+	}
+	return res, nil
+}
+
+// find returns the leftmost match of any pattern starting at or after pos,
+// preferring earlier patterns.
+func (m *perlMatcher) find(s string, pos int) ([]int, error) {
+	var best []int
+	for _, re := range m.res {
+		loc, err := re.FindStringSubmatchIndexAt(s, pos)
+		if err != nil {
+			return nil, err
+		}
+		if loc != nil && (best == nil || loc[0] < best[0]) {
+			best = loc[:2]
+		}
+	}
+	return best, nil
+}
@@ -0,0 +1,163 @@
+package grep
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"wb-grep/internal/config"
+)
+
+// printer writes selected lines with file names, line numbers, columns
+// and byte offsets, highlights matches and puts "--" separators between
+// non-adjacent groups of lines.
+type printer struct {
+	cfg     *config.Config
+	dst     io.Writer
+	name    string
+	match   Matcher
+	colors  Colors // the zero value when colors are off
+	sep     string // separator line, "" without context
+	lastNum int
+	broken  bool // the input restarted after lines were printed
+}
+
+func newPrinter(cfg *config.Config, dst io.Writer, name string, m Matcher) *printer {
+	p := &printer{
+		cfg:     cfg,
+		dst:     dst,
+		name:    name,
+		match:   m,
+		sep:     Separator(cfg),
+		colors:  colorsOf(cfg),
+		lastNum: -1,
+	}
+	return p
+}
+
+// Separator returns the line put between non-adjacent groups of context
+// lines, or "" when cfg prints no context.
+func Separator(cfg *config.Config) string {
+	if cfg.After == 0 && cfg.Before == 0 || cfg.OnlyMatching || cfg.JSON ||
+		cfg.Quiet || cfg.CountOnly || cfg.FilesWithMatches || cfg.FilesWithoutMatch {
+		return ""
+	}
+	c := colorsOf(cfg)
+	return c.wrap(c.Sep, "--") + "\n"
+}
+
+// print writes l, a selected line if matched is true and a context line
+// otherwise.
+func (p *printer) print(l line, matched bool) error {
+	if p.cfg.OnlyMatching {
+		return p.printMatches(l, matched)
+	}
+
+	var sb strings.Builder
+	if p.sep != "" && (p.broken || p.lastNum != -1 && l.num > p.lastNum+1) {
+		sb.WriteString(p.sep)
+	}
+	p.lastNum, p.broken = l.num, false
+
+	// Lines that contain a match are the selected ones, or the context
+	// lines with -v.
+	var spans [][]int
+	if matched != p.cfg.Invert && (p.cfg.Color || p.cfg.Column) {
+		var err error
+		if spans, err = p.match.FindAll(l.text, -1); err != nil {
+			return err
+		}
+	}
+
+	col := 0
+	if len(spans) > 0 {
+		col = spans[0][0] + 1
+	}
+	p.prefix(&sb, l, matched, col, l.off)
+
+	rest, hit := p.colors.Context, p.colors.ContextMatch
+	if matched {
+		rest, hit = p.colors.Selected, p.colors.Match
+	}
+	switch {
+	case !p.cfg.Color:
+		sb.WriteString(l.text)
+	case hit == "":
+		sb.WriteString(p.colors.wrap(rest, l.text))
+	default:
+		// As in GNU grep, the line color is turned on before the text in
+		// front of each match and only turned off after the match, so that
+		// the match color adds to it.
+		prev := 0
+		for _, s := range spans {
+			if s[0] == s[1] {
+				continue
+			}
+			sb.WriteString(p.colors.start(rest) + l.text[prev:s[0]])
+			sb.WriteString(p.colors.wrap(hit, l.text[s[0]:s[1]]))
+			prev = s[1]
+		}
+		sb.WriteString(p.colors.wrap(rest, l.text[prev:]))
+	}
+	sb.WriteByte('\n')
+
+	_, err := io.WriteString(p.dst, sb.String())
+	return err
+}
+
+func (p *printer) restart() {
+	if p.lastNum != -1 {
+		p.lastNum, p.broken = -1, true
+	}
+}
+
+// printMatches writes every non-empty match of a selected line on its own
+// line for -o. Context lines and the lines selected with -v have nothing
+// to print.
+func (p *printer) printMatches(l line, matched bool) error {
+	if !matched || p.cfg.Invert {
+		return nil
+	}
+
+	spans, err := p.match.FindAll(l.text, -1)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for _, s := range spans {
+		if s[0] == s[1] {
+			continue
+		}
+		p.prefix(&sb, l, true, s[0]+1, l.off+int64(s[0]))
+		sb.WriteString(p.colors.wrap(p.colors.Match, l.text[s[0]:s[1]]))
+		sb.WriteByte('\n')
+	}
+
+	_, err = io.WriteString(p.dst, sb.String())
+	return err
+}
+
+// prefix writes the file name, line number, column and byte offset of a
+// line, each followed by ":" for selected lines and "-" for context lines.
+// A zero col means the line has no match and the column is left out.
+func (p *printer) prefix(sb *strings.Builder, l line, matched bool, col int, off int64) {
+	sep := "-"
+	if matched {
+		sep = ":"
+	}
+	sep = p.colors.wrap(p.colors.Sep, sep)
+
+	if p.cfg.WithFilename {
+		sb.WriteString(p.colors.wrap(p.colors.File, p.name) + sep)
+	}
+	if p.cfg.LineNum {
+		sb.WriteString(p.colors.wrap(p.colors.Line, strconv.Itoa(l.num)) + sep)
+	}
+	if p.cfg.Column && col > 0 {
+		sb.WriteString(p.colors.wrap(p.colors.Line, strconv.Itoa(col)) + sep)
+	}
+	if p.cfg.ByteOffset {
+		sb.WriteString(p.colors.wrap(p.colors.Byte, strconv.FormatInt(off, 10)) + sep)
+	}
+}
@@ -0,0 +1,55 @@
+package ignore
+
+import "testing"
+
+func TestMatcher_Ignored(t *testing.T) {
+	m := New()
+	for _, p := range []string{
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"build/",
+		"/root.txt",
+		"docs/*.md",
+		"**/tmp",
+		"cache/**",
+		`\#hash`,
+		"[ab]?.bin",
+	} {
+		m.Add("", p)
+	}
+	m.Add("sub", "local.txt")
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.log", false, true},
+		{"deep/dir/a.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"root.txt", false, true},
+		{"src/root.txt", false, false},
+		{"docs/a.md", false, true},
+		{"docs/x/a.md", false, false},
+		{"a/b/tmp", true, true},
+		{"cache/x/y", false, true},
+		{"#hash", false, true},
+		{"a1.bin", false, true},
+		{"c1.bin", false, false},
+		{"sub/local.txt", false, true},
+		{"sub/deeper/local.txt", false, true},
+		{"local.txt", false, false},
+		{"other/local.txt", false, false},
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		if got := m.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
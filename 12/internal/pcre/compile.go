@@ -0,0 +1,240 @@
+package pcre
+
+import "errors"
+
+// maxInsts limits the size of a compiled program, repetition counts are
+// expanded into copies of the repeated item.
+const maxInsts = 100000
+
+var errTooLarge = errors.New("pcre: pattern is too large")
+
+type opcode uint8
+
+const (
+	opRune opcode = iota
+	opAny
+	opClass
+	opBOL
+	opEOL
+	opWordB
+	opNotWordB
+	opSave     // save the position to capture slot n
+	opSplit    // try x, then y
+	opJmp      // go to x
+	opMark     // save the position to loop mark n
+	opProgress // go to x if the position did not move since mark n
+	opBackref
+	opLook
+	opAtomic
+	opMatch
+)
+
+type inst struct {
+	op     opcode
+	r      rune
+	fold   bool
+	cls    *class
+	x, y   int
+	n      int
+	sub    *prog // opLook and opAtomic
+	neg    bool  // opLook
+	behind bool  // opLook
+	width  int   // opLook behind, the longest body in runes or -1 if unbounded
+}
+
+type prog struct {
+	insts []inst
+}
+
+// compiler compiles a tree into a program. Lookaround and atomic bodies
+// are compiled into programs of their own, sharing the counters.
+type compiler struct {
+	insts  []inst
+	shared *counters
+}
+
+type counters struct {
+	marks int
+	insts int
+}
+
+func compile(n *node) (*prog, int, error) {
+	c := &compiler{shared: &counters{}}
+	c.emit(inst{op: opSave, n: 0})
+	c.compile(n)
+	c.emit(inst{op: opSave, n: 1})
+	c.emit(inst{op: opMatch})
+	if c.shared.insts > maxInsts {
+		return nil, 0, errTooLarge
+	}
+	return &prog{insts: c.insts}, c.shared.marks, nil
+}
+
+func (c *compiler) emit(in inst) int {
+	c.shared.insts++
+	c.insts = append(c.insts, in)
+	return len(c.insts) - 1
+}
+
+func (c *compiler) compile(n *node) {
+	if c.shared.insts > maxInsts {
+		return
+	}
+
+	switch n.kind {
+	case nEmpty:
+	case nLit:
+		c.emit(inst{op: opRune, r: n.r, fold: n.fold})
+	case nAny:
+		c.emit(inst{op: opAny})
+	case nClass:
+		c.emit(inst{op: opClass, cls: n.cls, fold: n.fold})
+	case nConcat:
+		for _, s := range n.subs {
+			c.compile(s)
+		}
+	case nAlt:
+		var jmps []int
+		for i, s := range n.subs {
+			if i == len(n.subs)-1 {
+				c.compile(s)
+				break
+			}
+			split := c.emit(inst{op: opSplit})
+			c.insts[split].x = len(c.insts)
+			c.compile(s)
+			jmps = append(jmps, c.emit(inst{op: opJmp}))
+			c.insts[split].y = len(c.insts)
+		}
+		for _, j := range jmps {
+			c.insts[j].x = len(c.insts)
+		}
+	case nCapture:
+		c.emit(inst{op: opSave, n: 2 * n.n})
+		c.compile(n.subs[0])
+		c.emit(inst{op: opSave, n: 2*n.n + 1})
+	case nBOL:
+		c.emit(inst{op: opBOL})
+	case nEOL:
+		c.emit(inst{op: opEOL})
+	case nWordB:
+		c.emit(inst{op: opWordB})
+	case nNotWordB:
+		c.emit(inst{op: opNotWordB})
+	case nBackref:
+		c.emit(inst{op: opBackref, n: n.n, fold: n.fold})
+	case nLook:
+		c.emit(inst{
+			op:     opLook,
+			sub:    c.subprog(n.subs[0]),
+			neg:    n.neg,
+			behind: n.behind,
+			width:  width(n.subs[0]),
+		})
+	case nAtomic:
+		c.emit(inst{op: opAtomic, sub: c.subprog(n.subs[0])})
+	case nRepeat:
+		c.repeat(n)
+	}
+}
+
+func (c *compiler) subprog(n *node) *prog {
+	sc := &compiler{shared: c.shared}
+	sc.compile(n)
+	sc.emit(inst{op: opMatch})
+	return &prog{insts: sc.insts}
+}
+
+// repeat expands x{min,max} into min copies of x followed by either a loop
+// or max-min nested optional copies.
+func (c *compiler) repeat(n *node) {
+	sub := n.subs[0]
+	for range n.min {
+		c.compile(sub)
+	}
+
+	if n.max < 0 {
+		c.star(sub, n.lazy)
+		return
+	}
+
+	var splits []int
+	for range n.max - n.min {
+		splits = append(splits, c.emit(inst{op: opSplit}))
+		c.compile(sub)
+	}
+	end := len(c.insts)
+	for _, s := range splits {
+		c.branch(s, s+1, end, n.lazy)
+	}
+}
+
+// star compiles x*. As in Perl, an iteration that matches the empty
+// string ends the loop and the match goes on after it, so that the loop
+// always terminates.
+func (c *compiler) star(sub *node, lazy bool) {
+	split := c.emit(inst{op: opSplit})
+	mark := c.shared.marks
+	c.shared.marks++
+
+	c.emit(inst{op: opMark, n: mark})
+	c.compile(sub)
+	progress := c.emit(inst{op: opProgress, n: mark})
+	c.emit(inst{op: opJmp, x: split})
+	end := len(c.insts)
+	c.insts[progress].x = end
+	c.branch(split, split+1, end, lazy)
+}
+
+// branch makes the split at pc prefer body to skip, or the other way
+// round for lazy quantifiers.
+func (c *compiler) branch(pc, body, skip int, lazy bool) {
+	if lazy {
+		c.insts[pc].x, c.insts[pc].y = skip, body
+	} else {
+		c.insts[pc].x, c.insts[pc].y = body, skip
+	}
+}
+
+// width returns the largest number of runes n can match, or -1 if there
+// is no limit.
+func width(n *node) int {
+	switch n.kind {
+	case nLit, nAny, nClass:
+		return 1
+	case nConcat:
+		total := 0
+		for _, s := range n.subs {
+			w := width(s)
+			if w < 0 {
+				return -1
+			}
+			total += w
+		}
+		return total
+	case nAlt:
+		most := 0
+		for _, s := range n.subs {
+			w := width(s)
+			if w < 0 {
+				return -1
+			}
+			most = max(most, w)
+		}
+		return most
+	case nRepeat:
+		w := width(n.subs[0])
+		if w == 0 {
+			return 0
+		}
+		if w < 0 || n.max < 0 {
+			return -1
+		}
+		return w * n.max
+	case nCapture, nAtomic:
+		return width(n.subs[0])
+	case nBackref:
+		return -1
+	}
+	return 0
+}
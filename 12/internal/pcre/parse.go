@@ -0,0 +1,769 @@
+package pcre
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxRepeat is the largest count allowed in {n,m}.
+const maxRepeat = 1000
+
+type nodeKind uint8
+
+const (
+	nEmpty nodeKind = iota
+	nLit
+	nAny
+	nClass
+	nConcat
+	nAlt
+	nRepeat
+	nCapture
+	nBOL
+	nEOL
+	nWordB
+	nNotWordB
+	nBackref
+	nLook
+	nAtomic
+)
+
+// node is a node of the syntax tree.
+type node struct {
+	kind     nodeKind
+	r        rune   // nLit
+	cls      *class // nClass
+	fold     bool   // nLit, nClass, nBackref
+	subs     []*node
+	min, max int  // nRepeat, max is -1 for no limit
+	lazy     bool // nRepeat
+	n        int  // nCapture and nBackref group number
+	neg      bool // nLook
+	behind   bool // nLook
+}
+
+// flags are the inline options set with (?i) and friends.
+type flags struct {
+	fold bool
+}
+
+type parser struct {
+	s     string
+	pos   int
+	ncap  int
+	names map[string]int
+}
+
+// parse parses expr and returns its tree and the number of groups.
+func parse(expr string) (*node, int, error) {
+	p := &parser{s: expr, names: map[string]int{}}
+	n, err := p.parseAlt(flags{})
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.more() {
+		return nil, 0, p.errorf("unmatched )")
+	}
+	if err := p.checkRefs(n); err != nil {
+		return nil, 0, err
+	}
+	return n, p.ncap, nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("pcre: %s at offset %d of %q", fmt.Sprintf(format, args...), p.pos, p.s)
+}
+
+func (p *parser) more() bool {
+	return p.pos < len(p.s)
+}
+
+func (p *parser) peek() rune {
+	r, _ := utf8.DecodeRuneInString(p.s[p.pos:])
+	return r
+}
+
+func (p *parser) next() rune {
+	r, w := utf8.DecodeRuneInString(p.s[p.pos:])
+	p.pos += w
+	return r
+}
+
+func (p *parser) lookingAt(prefix string) bool {
+	return strings.HasPrefix(p.s[p.pos:], prefix)
+}
+
+func (p *parser) expect(c rune) error {
+	if !p.more() || p.next() != c {
+		return p.errorf("missing %c", c)
+	}
+	return nil
+}
+
+// parseAlt parses alternatives up to the end of the enclosing group.
+// Flags set inside apply until the end of the group.
+func (p *parser) parseAlt(f flags) (*node, error) {
+	var alts []*node
+	for {
+		c, err := p.parseConcat(&f)
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, c)
+		if !p.more() || p.peek() != '|' {
+			break
+		}
+		p.pos++
+	}
+
+	if len(alts) == 1 {
+		return alts[0], nil
+	}
+	return &node{kind: nAlt, subs: alts}, nil
+}
+
+func (p *parser) parseConcat(f *flags) (*node, error) {
+	var items []*node
+	for p.more() {
+		if c := p.peek(); c == '|' || c == ')' {
+			break
+		}
+
+		atom, err := p.parseAtom(f)
+		if err != nil {
+			return nil, err
+		}
+		if atom == nil {
+			// An option setting like (?i), there is nothing to repeat.
+			continue
+		}
+		if atom, err = p.parseRepeat(atom); err != nil {
+			return nil, err
+		}
+		items = append(items, atom)
+	}
+
+	switch len(items) {
+	case 0:
+		return &node{kind: nEmpty}, nil
+	case 1:
+		return items[0], nil
+	}
+	return &node{kind: nConcat, subs: items}, nil
+}
+
+func (p *parser) parseAtom(f *flags) (*node, error) {
+	switch c := p.next(); c {
+	case '(':
+		return p.parseGroup(f)
+	case '[':
+		cls, err := p.parseClass()
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nClass, cls: cls, fold: f.fold}, nil
+	case '.':
+		return &node{kind: nAny}, nil
+	case '^':
+		return &node{kind: nBOL}, nil
+	case '$':
+		return &node{kind: nEOL}, nil
+	case '\\':
+		return p.parseEscape(f)
+	case '*', '+', '?':
+		return nil, p.errorf("quantifier does not follow a repeatable item")
+	default:
+		return &node{kind: nLit, r: c, fold: f.fold}, nil
+	}
+}
+
+// parseGroup parses a group after its "(".
+func (p *parser) parseGroup(f *flags) (*node, error) {
+	if !p.lookingAt("?") {
+		p.ncap++
+		return p.parseCapture(*f, p.ncap)
+	}
+	p.pos++
+
+	switch {
+	case p.lookingAt(":"):
+		p.pos++
+		return p.parseSub(*f)
+	case p.lookingAt("="), p.lookingAt("!"):
+		neg := p.next() == '!'
+		sub, err := p.parseSub(*f)
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nLook, neg: neg, subs: []*node{sub}}, nil
+	case p.lookingAt("<="), p.lookingAt("<!"):
+		p.pos++
+		neg := p.next() == '!'
+		sub, err := p.parseSub(*f)
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nLook, neg: neg, behind: true, subs: []*node{sub}}, nil
+	case p.lookingAt(">"):
+		p.pos++
+		sub, err := p.parseSub(*f)
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nAtomic, subs: []*node{sub}}, nil
+	case p.lookingAt("<"), p.lookingAt("P<"), p.lookingAt("'"):
+		if p.peek() == 'P' {
+			p.pos++
+		}
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.names[name]; ok {
+			return nil, p.errorf("duplicate group name %q", name)
+		}
+		p.ncap++
+		p.names[name] = p.ncap
+		return p.parseCapture(*f, p.ncap)
+	case p.lookingAt("P="):
+		p.pos++
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return nil, p.errorf("missing )")
+		}
+		name := p.s[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		return p.namedRef(name, f)
+	}
+
+	// Option setting, for the rest of the group or for a (?i:...) group.
+	nf := *f
+	set := true
+	for p.more() {
+		switch c := p.next(); c {
+		case '-':
+			set = false
+		case 'i':
+			nf.fold = set
+		case 'm', 's':
+			// Lines never contain newlines, so these change nothing.
+		case ')':
+			*f = nf
+			return nil, nil
+		case ':':
+			return p.parseSub(nf)
+		default:
+			return nil, p.errorf("unknown option %q", c)
+		}
+	}
+	return nil, p.errorf("missing )")
+}
+
+func (p *parser) parseCapture(f flags, n int) (*node, error) {
+	sub, err := p.parseSub(f)
+	if err != nil {
+		return nil, err
+	}
+	return &node{kind: nCapture, n: n, subs: []*node{sub}}, nil
+}
+
+// parseSub parses the body of a group and its closing ")".
+func (p *parser) parseSub(f flags) (*node, error) {
+	sub, err := p.parseAlt(f)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// parseName parses a group name in angle brackets, braces or quotes. The
+// opening one is the next rune.
+func (p *parser) parseName() (string, error) {
+	var closing byte
+	switch p.next() {
+	case '<':
+		closing = '>'
+	case '{':
+		closing = '}'
+	case '\'':
+		closing = '\''
+	default:
+		return "", p.errorf("group name expected")
+	}
+
+	end := strings.IndexByte(p.s[p.pos:], closing)
+	if end <= 0 {
+		return "", p.errorf("invalid group name")
+	}
+	name := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return name, nil
+}
+
+func (p *parser) namedRef(name string, f *flags) (*node, error) {
+	n, ok := p.names[name]
+	if !ok {
+		return nil, p.errorf("reference to unknown group %q", name)
+	}
+	return &node{kind: nBackref, n: n, fold: f.fold}, nil
+}
+
+// parseRepeat parses a quantifier following atom, if there is one.
+func (p *parser) parseRepeat(atom *node) (*node, error) {
+	if !p.more() {
+		return atom, nil
+	}
+
+	min, max := 0, -1
+	switch p.peek() {
+	case '*':
+		p.pos++
+	case '+':
+		p.pos++
+		min = 1
+	case '?':
+		p.pos++
+		max = 1
+	case '{':
+		var ok bool
+		if min, max, ok = p.parseBraces(); !ok {
+			// Not a quantifier, the brace is a literal.
+			return atom, nil
+		}
+	default:
+		return atom, nil
+	}
+	if min > maxRepeat || max > maxRepeat {
+		return nil, p.errorf("repetition count is too large")
+	}
+	if max >= 0 && min > max {
+		return nil, p.errorf("numbers out of order in {} quantifier")
+	}
+
+	n := &node{kind: nRepeat, min: min, max: max, subs: []*node{atom}}
+	if p.lookingAt("?") {
+		p.pos++
+		n.lazy = true
+	} else if p.lookingAt("+") {
+		// Possessive, the same as an atomic group.
+		p.pos++
+		n = &node{kind: nAtomic, subs: []*node{n}}
+	}
+
+	if p.more() && strings.ContainsRune("*+?", p.peek()) {
+		return nil, p.errorf("quantifier does not follow a repeatable item")
+	}
+	return n, nil
+}
+
+// parseBraces parses {n}, {n,} or {n,m}. It leaves the position as is and
+// returns false if the text is not a quantifier.
+func (p *parser) parseBraces() (int, int, bool) {
+	end := strings.IndexByte(p.s[p.pos:], '}')
+	if end < 0 {
+		return 0, 0, false
+	}
+	body := p.s[p.pos+1 : p.pos+end]
+
+	lo, hi, comma := strings.Cut(body, ",")
+	min, err := strconv.Atoi(lo)
+	if err != nil || min < 0 {
+		return 0, 0, false
+	}
+	max := min
+	if comma {
+		max = -1
+		if hi != "" {
+			if max, err = strconv.Atoi(hi); err != nil || max < 0 {
+				return 0, 0, false
+			}
+		}
+	}
+
+	p.pos += end + 1
+	return min, max, true
+}
+
+// parseEscape parses an escape sequence outside a class, after its "\".
+func (p *parser) parseEscape(f *flags) (*node, error) {
+	if !p.more() {
+		return nil, p.errorf("\\ at end of pattern")
+	}
+
+	switch c := p.next(); c {
+	case 'd', 'D', 'w', 'W', 's', 'S', 'p', 'P':
+		match, err := p.classEscape(c)
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nClass, cls: &class{funcs: []func(rune) bool{match}}}, nil
+	case 'b':
+		return &node{kind: nWordB}, nil
+	case 'B':
+		return &node{kind: nNotWordB}, nil
+	case 'A':
+		return &node{kind: nBOL}, nil
+	case 'z', 'Z':
+		return &node{kind: nEOL}, nil
+	case 'k':
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		return p.namedRef(name, f)
+	case 'g':
+		braced := p.lookingAt("{")
+		if braced {
+			p.pos++
+		}
+		n, err := p.parseRefNumber()
+		if err != nil {
+			return nil, err
+		}
+		if braced {
+			if err := p.expect('}'); err != nil {
+				return nil, err
+			}
+		}
+		return &node{kind: nBackref, n: n, fold: f.fold}, nil
+	case 'Q':
+		end := strings.Index(p.s[p.pos:], `\E`)
+		if end < 0 {
+			end = len(p.s) - p.pos
+		}
+		quoted := p.s[p.pos : p.pos+end]
+		p.pos = min(p.pos+end+2, len(p.s))
+
+		cat := &node{kind: nConcat}
+		for _, r := range quoted {
+			cat.subs = append(cat.subs, &node{kind: nLit, r: r, fold: f.fold})
+		}
+		return cat, nil
+	case 'E':
+		return &node{kind: nEmpty}, nil
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		p.pos--
+		n, err := p.parseRefNumber()
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nBackref, n: n, fold: f.fold}, nil
+	default:
+		r, err := p.runeEscape(c)
+		if err != nil {
+			return nil, err
+		}
+		return &node{kind: nLit, r: r, fold: f.fold}, nil
+	}
+}
+
+// parseRefNumber parses the group number of a backreference. A negative
+// number refers to the groups opened before the reference.
+func (p *parser) parseRefNumber() (int, error) {
+	start := p.pos
+	if p.lookingAt("-") {
+		p.pos++
+	}
+	for p.more() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil || n == 0 {
+		return 0, p.errorf("invalid backreference")
+	}
+	if n < 0 {
+		n += p.ncap + 1
+		if n <= 0 {
+			return 0, p.errorf("invalid backreference")
+		}
+	}
+	return n, nil
+}
+
+// runeEscape returns the rune of an escape sequence that stands for a
+// single rune, c is the rune after the "\".
+func (p *parser) runeEscape(c rune) (rune, error) {
+	switch c {
+	case 't':
+		return '\t', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 'f':
+		return '\f', nil
+	case 'e':
+		return 0x1b, nil
+	case 'a':
+		return 7, nil
+	case '0':
+		v := 0
+		for i := 0; i < 2 && p.more() && p.peek() >= '0' && p.peek() <= '7'; i++ {
+			v = v*8 + int(p.next()-'0')
+		}
+		return rune(v), nil
+	case 'x':
+		digits := ""
+		if p.lookingAt("{") {
+			end := strings.IndexByte(p.s[p.pos:], '}')
+			if end < 0 {
+				return 0, p.errorf("missing } in \\x{}")
+			}
+			digits = p.s[p.pos+1 : p.pos+end]
+			p.pos += end + 1
+		} else {
+			start := p.pos
+			for i := 0; i < 2 && p.more() && isHex(p.peek()); i++ {
+				p.pos++
+			}
+			digits = p.s[start:p.pos]
+		}
+		if digits == "" {
+			return 0, nil
+		}
+		v, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || v > unicode.MaxRune {
+			return 0, p.errorf("invalid \\x escape")
+		}
+		return rune(v), nil
+	}
+
+	if c < utf8.RuneSelf && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+		return 0, p.errorf("unknown escape \\%c", c)
+	}
+	return c, nil
+}
+
+func isHex(r rune) bool {
+	return r >= '0' && r <= '9' || r >= 'a' && r <= 'f' || r >= 'A' && r <= 'F'
+}
+
+// classEscape returns the predicate of \d, \w, \s, \p{...} and their
+// negations, c is the rune after the "\".
+func (p *parser) classEscape(c rune) (func(rune) bool, error) {
+	var match func(rune) bool
+	switch unicode.ToLower(c) {
+	case 'd':
+		match = unicode.IsDigit
+	case 'w':
+		match = isWordRune
+	case 's':
+		match = unicode.IsSpace
+	case 'p':
+		table, neg, err := p.parseProperty()
+		if err != nil {
+			return nil, err
+		}
+		match = func(r rune) bool { return unicode.Is(table, r) != neg }
+	}
+
+	if unicode.IsUpper(c) {
+		return func(r rune) bool { return !match(r) }, nil
+	}
+	return match, nil
+}
+
+// parseProperty parses the name of \p{Name}, \p{^Name} or \pL.
+func (p *parser) parseProperty() (*unicode.RangeTable, bool, error) {
+	if !p.more() {
+		return nil, false, p.errorf("malformed \\p")
+	}
+
+	name := string(p.next())
+	if name == "{" {
+		end := strings.IndexByte(p.s[p.pos:], '}')
+		if end < 0 {
+			return nil, false, p.errorf("malformed \\p")
+		}
+		name = p.s[p.pos : p.pos+end]
+		p.pos += end + 1
+	}
+
+	neg := strings.HasPrefix(name, "^")
+	name = strings.TrimPrefix(name, "^")
+	if name == "Any" {
+		return anyTable, neg, nil
+	}
+	if t, ok := unicode.Categories[name]; ok {
+		return t, neg, nil
+	}
+	if t, ok := unicode.Scripts[name]; ok {
+		return t, neg, nil
+	}
+	return nil, false, p.errorf("unknown property %q", name)
+}
+
+var anyTable = &unicode.RangeTable{R32: []unicode.Range32{{Lo: 0, Hi: unicode.MaxRune, Stride: 1}}}
+
+// class is a bracket expression or a class escape.
+type class struct {
+	neg    bool
+	ranges []rune // pairs of lo, hi
+	funcs  []func(rune) bool
+}
+
+func (c *class) has(r rune) bool {
+	for i := 0; i < len(c.ranges); i += 2 {
+		if c.ranges[i] <= r && r <= c.ranges[i+1] {
+			return true
+		}
+	}
+	for _, f := range c.funcs {
+		if f(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *class) matches(r rune, fold bool) bool {
+	ok := c.has(r)
+	if !ok && fold {
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if c.has(f) {
+				ok = true
+				break
+			}
+		}
+	}
+	return ok != c.neg
+}
+
+// parseClass parses a bracket expression after its "[".
+func (p *parser) parseClass() (*class, error) {
+	cls := &class{}
+	if p.lookingAt("^") {
+		p.pos++
+		cls.neg = true
+	}
+
+	for first := true; ; first = false {
+		if !p.more() {
+			return nil, p.errorf("missing terminating ] for character class")
+		}
+		if p.peek() == ']' && !first {
+			p.pos++
+			return cls, nil
+		}
+
+		if p.lookingAt("[:") {
+			ok, err := p.parsePosix(cls)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				continue
+			}
+		}
+
+		lo, isRune, err := p.classAtom(cls)
+		if err != nil {
+			return nil, err
+		}
+		if !isRune {
+			continue
+		}
+
+		hi := lo
+		if p.lookingAt("-") && !p.lookingAt("-]") && p.pos+1 < len(p.s) {
+			p.pos++
+			if hi, isRune, err = p.classAtom(cls); err != nil {
+				return nil, err
+			}
+			if !isRune || hi < lo {
+				return nil, p.errorf("invalid range in character class")
+			}
+		}
+		cls.ranges = append(cls.ranges, lo, hi)
+	}
+}
+
+// classAtom parses a rune of a class or adds a class escape to cls, in
+// which case it returns false.
+func (p *parser) classAtom(cls *class) (rune, bool, error) {
+	c := p.next()
+	if c != '\\' {
+		return c, true, nil
+	}
+	if !p.more() {
+		return 0, false, p.errorf("\\ at end of pattern")
+	}
+
+	switch c = p.next(); c {
+	case 'd', 'D', 'w', 'W', 's', 'S', 'p', 'P':
+		match, err := p.classEscape(c)
+		if err != nil {
+			return 0, false, err
+		}
+		cls.funcs = append(cls.funcs, match)
+		return 0, false, nil
+	case 'b':
+		return '\b', true, nil
+	}
+	r, err := p.runeEscape(c)
+	return r, err == nil, err
+}
+
+var posixClasses = map[string]func(rune) bool{
+	"alpha":  unicode.IsLetter,
+	"digit":  func(r rune) bool { return r >= '0' && r <= '9' },
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"space":  unicode.IsSpace,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"upper":  unicode.IsUpper,
+	"lower":  unicode.IsLower,
+	"punct":  unicode.IsPunct,
+	"cntrl":  unicode.IsControl,
+	"print":  unicode.IsPrint,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"xdigit": isHex,
+	"word":   isWordRune,
+}
+
+// parsePosix parses [:name:] or [:^name:] inside a class. It returns false
+// if the text is not a POSIX class, so that "[" is taken literally.
+func (p *parser) parsePosix(cls *class) (bool, error) {
+	end := strings.Index(p.s[p.pos:], ":]")
+	if end < 0 {
+		return false, nil
+	}
+	name := p.s[p.pos+2 : p.pos+end]
+
+	neg := strings.HasPrefix(name, "^")
+	match, ok := posixClasses[strings.TrimPrefix(name, "^")]
+	if !ok {
+		return false, p.errorf("unknown POSIX class name %q", name)
+	}
+	p.pos += end + 2
+
+	if neg {
+		cls.funcs = append(cls.funcs, func(r rune) bool { return !match(r) })
+	} else {
+		cls.funcs = append(cls.funcs, match)
+	}
+	return true, nil
+}
+
+// checkRefs reports backreferences to groups that do not exist.
+func (p *parser) checkRefs(n *node) error {
+	if n.kind == nBackref && n.n > p.ncap {
+		return fmt.Errorf("pcre: reference to non-existent group %d in %q", n.n, p.s)
+	}
+	for _, s := range n.subs {
+		if err := p.checkRefs(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,436 @@
+// Package pcre implements a backtracking regular expression engine with the
+// Perl features that RE2 leaves out: backreferences, lookahead, lookbehind
+// and atomic groups. Backtracking can take exponential time, so every
+// search is limited to a number of steps.
+package pcre
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultStepLimit is the number of steps a search may take by default.
+const DefaultStepLimit = 1000000
+
+// ErrStepLimit is returned by searches that take more than the step limit.
+var ErrStepLimit = errors.New("pcre: backtracking step limit exceeded")
+
+// Regexp is a compiled regular expression. It is safe for concurrent use.
+type Regexp struct {
+	expr      string
+	prog      *prog
+	ncap      int
+	nmarks    int
+	anchored  bool // the pattern starts with ^
+	prefix    rune // the first rune of every match, or -1
+	stepLimit int
+}
+
+// Compile parses a Perl-style regular expression. Inline options such as
+// (?i) are supported, \d, \w, \s and POSIX classes are Unicode aware.
+func Compile(expr string) (*Regexp, error) {
+	tree, ncap, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	p, nmarks, err := compile(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	re := &Regexp{
+		expr:      expr,
+		prog:      p,
+		ncap:      ncap,
+		nmarks:    nmarks,
+		prefix:    -1,
+		stepLimit: DefaultStepLimit,
+	}
+	switch first := p.insts[1]; {
+	case first.op == opBOL:
+		re.anchored = true
+	case first.op == opRune && !first.fold:
+		re.prefix = first.r
+	}
+	return re, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(expr string) *Regexp {
+	re, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// String returns the source text of the expression.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// NumSubexp returns the number of groups in the expression.
+func (re *Regexp) NumSubexp() int {
+	return re.ncap
+}
+
+// SetStepLimit sets the number of steps a single search may take.
+func (re *Regexp) SetStepLimit(n int) {
+	re.stepLimit = n
+}
+
+// MatchString reports whether s contains a match.
+func (re *Regexp) MatchString(s string) (bool, error) {
+	loc, err := re.FindStringSubmatchIndexAt(s, 0)
+	return loc != nil, err
+}
+
+// FindStringSubmatchIndex returns the offsets of the leftmost match and of
+// its groups, as regexp.FindStringSubmatchIndex does, or nil.
+func (re *Regexp) FindStringSubmatchIndex(s string) ([]int, error) {
+	return re.FindStringSubmatchIndexAt(s, 0)
+}
+
+// FindAllStringIndex returns up to n successive matches of the expression,
+// all of them if n is negative. As with regexp, an empty match right after
+// the previous match is skipped.
+func (re *Regexp) FindAllStringIndex(s string, n int) ([][]int, error) {
+	var res [][]int
+	prevEnd := -1
+	for pos := 0; pos <= len(s) && (n < 0 || len(res) < n); {
+		loc, err := re.FindStringSubmatchIndexAt(s, pos)
+		if err != nil {
+			return nil, err
+		}
+		if loc == nil {
+			break
+		}
+
+		start, end := loc[0], loc[1]
+		if start == end && start == prevEnd {
+			pos = start + runeLen(s, start)
+			continue
+		}
+
+		res = append(res, []int{start, end})
+		prevEnd = end
+		pos = end
+		if start == end {
+			pos += runeLen(s, end)
+		}
+	}
+	return res, nil
+}
+
+// FindStringSubmatchIndexAt is like FindStringSubmatchIndex but only looks
+// for matches that start at or after pos. The text before pos is still seen
+// by lookbehind and \b.
+func (re *Regexp) FindStringSubmatchIndexAt(s string, pos int) ([]int, error) {
+	m := &machine{
+		s:     s,
+		caps:  make([]int, 2*(re.ncap+1)),
+		marks: make([]int, re.nmarks),
+		limit: re.stepLimit,
+	}
+	for i := range m.caps {
+		m.caps[i] = -1
+	}
+
+	for start := pos; start <= len(s); start += runeLen(s, start) {
+		if re.anchored && start > 0 {
+			break
+		}
+		if re.prefix >= 0 {
+			i := strings.IndexRune(s[start:], re.prefix)
+			if i < 0 {
+				break
+			}
+			start += i
+		}
+
+		end, err := m.run(re.prog, start, -1)
+		if err != nil {
+			return nil, err
+		}
+		if end >= 0 {
+			return m.caps, nil
+		}
+	}
+	return nil, nil
+}
+
+// entry is an entry of the backtracking stack: a thread to resume or a
+// capture or loop mark to restore.
+type entry struct {
+	kind uint8
+	pc   int // the slot for restores
+	pos  int // the old value for restores
+}
+
+const (
+	branch uint8 = iota
+	restoreCap
+	restoreMark
+)
+
+type machine struct {
+	s     string
+	caps  []int
+	marks []int
+	stack []entry
+	steps int
+	limit int
+}
+
+// run runs p from pos and returns the end of the match, or -1. With end
+// >= 0 only a match ending there is accepted. On success the restores of
+// the changes it made are left on the stack.
+func (m *machine) run(p *prog, pos, end int) (int, error) {
+	base := len(m.stack)
+	m.stack = append(m.stack, entry{kind: branch, pc: 0, pos: pos})
+
+	for len(m.stack) > base {
+		e := m.stack[len(m.stack)-1]
+		m.stack = m.stack[:len(m.stack)-1]
+		switch e.kind {
+		case restoreCap:
+			m.caps[e.pc] = e.pos
+			continue
+		case restoreMark:
+			m.marks[e.pc] = e.pos
+			continue
+		}
+
+		pc, pos := e.pc, e.pos
+	thread:
+		for {
+			m.steps++
+			if m.steps > m.limit {
+				return -1, ErrStepLimit
+			}
+
+			in := &p.insts[pc]
+			switch in.op {
+			case opRune:
+				if pos >= len(m.s) {
+					break thread
+				}
+				r, w := utf8.DecodeRuneInString(m.s[pos:])
+				if r != in.r && !(in.fold && foldEqual(r, in.r)) {
+					break thread
+				}
+				pos += w
+			case opAny:
+				if pos >= len(m.s) {
+					break thread
+				}
+				r, w := utf8.DecodeRuneInString(m.s[pos:])
+				if r == '\n' {
+					break thread
+				}
+				pos += w
+			case opClass:
+				if pos >= len(m.s) {
+					break thread
+				}
+				r, w := utf8.DecodeRuneInString(m.s[pos:])
+				if !in.cls.matches(r, in.fold) {
+					break thread
+				}
+				pos += w
+			case opBOL:
+				if pos != 0 {
+					break thread
+				}
+			case opEOL:
+				if pos != len(m.s) {
+					break thread
+				}
+			case opWordB, opNotWordB:
+				if isBoundary(m.s, pos) != (in.op == opWordB) {
+					break thread
+				}
+			case opSave:
+				m.stack = append(m.stack, entry{kind: restoreCap, pc: in.n, pos: m.caps[in.n]})
+				m.caps[in.n] = pos
+			case opMark:
+				m.stack = append(m.stack, entry{kind: restoreMark, pc: in.n, pos: m.marks[in.n]})
+				m.marks[in.n] = pos
+			case opProgress:
+				if m.marks[in.n] == pos {
+					pc = in.x
+					continue
+				}
+			case opSplit:
+				m.stack = append(m.stack, entry{kind: branch, pc: in.y, pos: pos})
+				pc = in.x
+				continue
+			case opJmp:
+				pc = in.x
+				continue
+			case opBackref:
+				start, stop := m.caps[2*in.n], m.caps[2*in.n+1]
+				if start < 0 || stop < 0 {
+					break thread
+				}
+				n, ok := matchRef(m.s[pos:], m.s[start:stop], in.fold)
+				if !ok {
+					break thread
+				}
+				pos += n
+			case opLook:
+				ok, err := m.look(in, pos)
+				if err != nil {
+					return -1, err
+				}
+				if !ok {
+					break thread
+				}
+			case opAtomic:
+				sub := len(m.stack)
+				e, err := m.run(in.sub, pos, -1)
+				if err != nil {
+					return -1, err
+				}
+				if e < 0 {
+					break thread
+				}
+				m.commit(sub)
+				pos = e
+			case opMatch:
+				if end >= 0 && pos != end {
+					break thread
+				}
+				return pos, nil
+			}
+			pc++
+		}
+	}
+	return -1, nil
+}
+
+// look checks a lookaround assertion at pos. Lookbehind tries the starts
+// nearest to pos first, no further back than the body can reach.
+func (m *machine) look(in *inst, pos int) (bool, error) {
+	base := len(m.stack)
+	matched := false
+
+	if !in.behind {
+		e, err := m.run(in.sub, pos, -1)
+		if err != nil {
+			return false, err
+		}
+		matched = e >= 0
+	} else {
+		for start, n := pos, 0; in.width < 0 || n <= in.width; n++ {
+			e, err := m.run(in.sub, start, pos)
+			if err != nil {
+				return false, err
+			}
+			if e >= 0 {
+				matched = true
+				break
+			}
+			if start == 0 {
+				break
+			}
+			_, w := utf8.DecodeLastRuneInString(m.s[:start])
+			start -= w
+		}
+	}
+
+	switch {
+	case !matched:
+		return in.neg, nil
+	case in.neg:
+		m.undo(base)
+		return false, nil
+	}
+	m.commit(base)
+	return true, nil
+}
+
+// commit drops the threads a successful sub-match left above base, so that
+// it is not retried, and keeps the restores of the groups it set.
+func (m *machine) commit(base int) {
+	kept := m.stack[:base]
+	for _, e := range m.stack[base:] {
+		if e.kind == restoreCap {
+			kept = append(kept, e)
+		}
+	}
+	m.stack = kept
+}
+
+// undo reverts the changes of a sub-match that is not used.
+func (m *machine) undo(base int) {
+	for i := len(m.stack) - 1; i >= base; i-- {
+		switch e := m.stack[i]; e.kind {
+		case restoreCap:
+			m.caps[e.pc] = e.pos
+		case restoreMark:
+			m.marks[e.pc] = e.pos
+		}
+	}
+	m.stack = m.stack[:base]
+}
+
+// matchRef reports whether s starts with the text of a group and returns
+// the length of the matched prefix of s.
+func matchRef(s, ref string, fold bool) (int, bool) {
+	if !fold {
+		return len(ref), strings.HasPrefix(s, ref)
+	}
+
+	n := 0
+	for _, r := range ref {
+		if n >= len(s) {
+			return 0, false
+		}
+		c, w := utf8.DecodeRuneInString(s[n:])
+		if !foldEqual(c, r) {
+			return 0, false
+		}
+		n += w
+	}
+	return n, true
+}
+
+func foldEqual(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return false
+}
+
+// isBoundary reports whether pos is between a word rune and a non-word
+// rune or an end of s.
+func isBoundary(s string, pos int) bool {
+	before, after := false, false
+	if pos > 0 {
+		r, _ := utf8.DecodeLastRuneInString(s[:pos])
+		before = isWordRune(r)
+	}
+	if pos < len(s) {
+		r, _ := utf8.DecodeRuneInString(s[pos:])
+		after = isWordRune(r)
+	}
+	return before != after
+}
+
+// runeLen returns the length of the rune at s[i:], at least 1 so that
+// searches always move forward.
+func runeLen(s string, i int) int {
+	if i >= len(s) {
+		return 1
+	}
+	_, w := utf8.DecodeRuneInString(s[i:])
+	return w
+}
@@ -0,0 +1,188 @@
+package pcre
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRegexp_FindStringSubmatchIndex(t *testing.T) {
+	tests := []struct {
+		expr string
+		s    string
+		want []int
+	}{
+		{"abc", "xxabcxx", []int{2, 5}},
+		{"a+", "baaab", []int{1, 4}},
+		{"a+?", "baaab", []int{1, 2}},
+		{"a*", "bbb", []int{0, 0}},
+		{"colou?r", "my color", []int{3, 8}},
+		{"a{2,3}", "aaaa", []int{0, 3}},
+		{"a{2,}", "aaaa", []int{0, 4}},
+		{"a{2}", "aaaa", []int{0, 2}},
+		{"a{,2}", "a{,2}", []int{0, 5}},
+		{"x|xy", "xy", []int{0, 1}},
+		{"(a|ab)(c|bcd)", "abcd", []int{0, 4, 0, 1, 1, 4}},
+		{"^ab", "cab", nil},
+		{"ab$", "abab", []int{2, 4}},
+		{`\bfoo\b`, "foobar foo", []int{7, 10}},
+		{`\Bbar`, "bar foobar", []int{7, 10}},
+		{`[a-c]+`, "xxbcax", []int{2, 5}},
+		{`[^a-c ]+`, "abc def", []int{4, 7}},
+		{`[]a]+`, "x]a]", []int{1, 4}},
+		{`[a-]+`, "x-a-", []int{1, 4}},
+		{`[[:digit:]]+`, "ab12c", []int{2, 4}},
+		{`\d+`, "ab١٢c", []int{2, 6}},
+		{`\w+`, "  привет_1 ", []int{2, 16}},
+		{`\p{Cyrillic}+`, "abcдомabc", []int{3, 9}},
+		{`\P{L}+`, "ab12c", []int{2, 4}},
+		{`\x41\x{42}\t`, "AB\t", []int{0, 3}},
+		{`\Qa.b\E`, "axb a.b", []int{4, 7}},
+		{`(?i)привет`, "ПРИВЕТ", []int{0, 12}},
+		{`(?i:a)b`, "AbAB", []int{0, 2}},
+		{`a(?i)b`, "aB", []int{0, 2}},
+		{`(a)(?-i)b`, "aB", nil},
+
+		// Backreferences.
+		{`(\w)\1`, "abccd", []int{2, 4, 2, 3}},
+		{`(?i)(a)\1`, "aA", []int{0, 2, 0, 1}},
+		{`(?<q>["'])\w*\k<q>`, `"ab' 'cd'`, []int{5, 9, 5, 6}},
+		{`(?P<q>x)(?P=q)`, "xx", []int{0, 2, 0, 1}},
+		{`(a)\g{-1}`, "aa", []int{0, 2, 0, 1}},
+		{`(a)|\1b`, "b", nil},
+
+		// Lookaround.
+		{`foo(?=bar)`, "foobaz foobar", []int{7, 10}},
+		{`foo(?!bar)`, "foobar foobaz", []int{7, 10}},
+		{`(?<=\$)\d+`, "a1 $42", []int{4, 6}},
+		{`(?<!\$)\b\d+`, "$1 42", []int{3, 5}},
+		{`(?<=ab|c)x`, "bx abx", []int{5, 6}},
+		{`(?<=a\w*)x`, "bx aYYx", []int{6, 7}},
+		{`(?=(\w+))\w`, "ab", []int{0, 1, 0, 2}},
+
+		// Atomic groups and possessive quantifiers.
+		{`(?>a+)b`, "aab", []int{0, 3}},
+		{`(?>a+)a`, "aaa", nil},
+		{`a++a`, "aaa", nil},
+		{`(?>a|ab)c`, "abc", nil},
+
+		// Loops over items that can match the empty string terminate
+		// after the first empty iteration, which is kept, as in Perl.
+		{`(a*)*b`, "aab", []int{0, 3, 2, 2}},
+		{`(a|)*c`, "c", []int{0, 1, 0, 0}},
+		{`(|\w)*`, "abcab", []int{0, 0, 0, 0}},
+		{`.(?:a*?)+`, "xxaa", []int{0, 1}},
+		{`(?:a*?)+b`, "aab", []int{0, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+"/"+tt.s, func(t *testing.T) {
+			re, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := re.FindStringSubmatchIndex(tt.s)
+			if err != nil {
+				t.Fatalf("FindStringSubmatchIndex() error = %v", err)
+			}
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("got %v, want no match", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got[:len(tt.want)], tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for _, expr := range []string{
+		"(a", "a)", "[a", "*a", "a**", `\2(a)`, `a{3,2}`, `a{1001}`,
+		`\q`, `(?z)`, `\p{Nope}`, `(?<n>a)(?<n>b)`, `\k<n>`, `[z-a]`, `a\`,
+	} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("Compile(%q) error = nil, want an error", expr)
+		}
+	}
+}
+
+func TestRegexp_FindAllStringIndex(t *testing.T) {
+	re := MustCompile(`a*`)
+	got, err := re.FindAllStringIndex("baaac", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := regexp.MustCompile(`a*`).FindAllStringIndex("baaac", -1)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllStringIndex() = %v, want %v", got, want)
+	}
+
+	// An empty iteration ends the loop instead of taking another a.
+	got, _ = MustCompile(`.(?:a*?)+`).FindAllStringIndex("xxaa", -1)
+	if want := [][]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAllStringIndex() = %v, want %v", got, want)
+	}
+
+	got, _ = MustCompile(`(?<=-)\w+`).FindAllStringIndex("-ab-cd ef", 1)
+	if !reflect.DeepEqual(got, [][]int{{1, 3}}) {
+		t.Errorf("FindAllStringIndex(n=1) = %v", got)
+	}
+}
+
+func TestRegexp_StepLimit(t *testing.T) {
+	re := MustCompile(`(a+)+$`)
+	_, err := re.MatchString(strings.Repeat("a", 40) + "b")
+	if !errors.Is(err, ErrStepLimit) {
+		t.Errorf("MatchString() error = %v, want ErrStepLimit", err)
+	}
+
+	re.SetStepLimit(100)
+	if _, err := re.MatchString(strings.Repeat("a", 10) + "b"); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("MatchString() with a small limit error = %v, want ErrStepLimit", err)
+	}
+}
+
+// TestRegexp_RandomAgainstRE2 compares leftmost-first matches with the
+// regexp package on patterns both engines understand.
+func TestRegexp_RandomAgainstRE2(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	atoms := []string{"a", "b", "c", ".", "[ab]", "[^a]", `\w`, "(a|bc)", "(?:ab|a)", "x"}
+	quants := []string{"", "", "*", "+", "?", "{1,2}", "*?", "+?", "??"}
+
+	for i := 0; i < 2000; i++ {
+		var sb strings.Builder
+		for j := rnd.Intn(4) + 1; j > 0; j-- {
+			sb.WriteString(atoms[rnd.Intn(len(atoms))])
+			sb.WriteString(quants[rnd.Intn(len(quants))])
+		}
+		expr := sb.String()
+
+		text := make([]byte, rnd.Intn(10))
+		for j := range text {
+			text[j] = "abcx"[rnd.Intn(4)]
+		}
+
+		got, err := MustCompile(expr).FindAllStringIndex(string(text), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := regexp.MustCompile(expr).FindAllStringIndex(string(text), -1)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q on %q: got %v, want %v", expr, text, got, want)
+		}
+	}
+}
+
+func BenchmarkMatchString(b *testing.B) {
+	re := MustCompile(`(?<=user=)\w+(?= failed)`)
+	line := strings.Repeat("x", 100) + " user=alice failed login"
+	for range b.N {
+		re.MatchString(line)
+	}
+}
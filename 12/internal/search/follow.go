@@ -0,0 +1,116 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"wb-grep/internal/follow"
+	"wb-grep/internal/grep"
+)
+
+// followInterval is how often followed files are checked for new data.
+var followInterval = 200 * time.Millisecond
+
+// Follow searches the files as they grow until ctx is done or, with -q, a
+// line is selected. Every file is followed by its own goroutine with its
+// own context state, and output is written to dst a whole line at a time.
+func (s *Search) Follow(ctx context.Context, dst io.Writer) error {
+	m, err := grep.NewMatcher(s.cfg)
+	if err != nil {
+		return err
+	}
+	s.match = m
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// mu guards dst and the state of s.
+	var mu sync.Mutex
+	var werr error
+	var wg sync.WaitGroup
+	for _, path := range s.cfg.Files {
+		r, err := follow.Open(ctx, path, followInterval)
+		if err != nil {
+			mu.Lock()
+			s.report(err)
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer r.Close()
+
+			g := grep.NewFile(s.cfg, path, restartReader{r}, s.match)
+			err := g.Run(&lineWriter{mu: &mu, w: errWriter{w: dst}})
+
+			mu.Lock()
+			defer mu.Unlock()
+			s.matched = s.matched || g.Matches() > 0
+			switch {
+			case err == nil:
+			case isWriteError(err):
+				werr = err
+				cancel()
+			default:
+				s.report(fmt.Errorf("%s: %w", path, err))
+			}
+			if g.Matches() > 0 && s.cfg.Quiet {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if werr != nil {
+		return werr
+	}
+	if s.failed {
+		return ErrFiles
+	}
+	return nil
+}
+
+// restartReader passes the restarts of a followed file on to grep.
+type restartReader struct {
+	r io.Reader
+}
+
+func (r restartReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if errors.Is(err, follow.ErrRestart) {
+		err = grep.ErrRestart
+	}
+	return n, err
+}
+
+// lineWriter writes complete lines to w while holding mu, so that the
+// lines of files followed at the same time do not mix.
+type lineWriter struct {
+	mu  *sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	end := bytes.LastIndexByte(l.buf, '\n') + 1
+	if end == 0 {
+		return len(p), nil
+	}
+
+	l.mu.Lock()
+	_, err := l.w.Write(l.buf[:end])
+	l.mu.Unlock()
+	l.buf = l.buf[:copy(l.buf, l.buf[end:])]
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
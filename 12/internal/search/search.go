@@ -0,0 +1,507 @@
+// Package search runs grep over several files and directory trees.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"wb-grep/internal/config"
+	"wb-grep/internal/decompress"
+	"wb-grep/internal/grep"
+	"wb-grep/internal/ignore"
+)
+
+// ErrFiles is returned when some of the files could not be searched.
+// The reasons are reported to the error writer as they happen.
+var ErrFiles = errors.New("some files could not be searched")
+
+// errStopped is returned by searches cut short after -q found a match in
+// another file.
+var errStopped = errors.New("search stopped")
+
+// Search searches the files of a configuration.
+type Search struct {
+	cfg     *config.Config
+	stderr  io.Writer
+	match   grep.Matcher
+	failed  bool
+	matched bool
+	printed bool            // some file printed output
+	stop    <-chan struct{} // closed when -q found a match, nil otherwise
+}
+
+// New creates a Search that reports file errors to stderr.
+func New(cfg *config.Config, stderr io.Writer) *Search {
+	return &Search{
+		cfg:    cfg,
+		stderr: stderr,
+	}
+}
+
+// task is a file to search. Concurrent workers write its output to out
+// and report the result through done, so that the output of files is
+// written in walk order.
+type task struct {
+	path string // path to open, "-" for standard input
+	name string // name to print
+	out  *spool
+	done chan result
+}
+
+type result struct {
+	matched bool
+	err     error
+}
+
+// Run searches all files and writes the results to dst in the order the
+// files are listed and walked, no matter which worker finishes first.
+func (s *Search) Run(dst io.Writer) error {
+	m, err := grep.NewMatcher(s.cfg)
+	if err != nil {
+		return err
+	}
+	s.match = m
+
+	operands := s.cfg.Files
+	if len(operands) == 0 {
+		operands = []string{"-"}
+		if s.cfg.Recursive {
+			operands = []string{"."}
+		}
+	}
+
+	if s.cfg.Workers == 1 || (len(operands) == 1 && !s.cfg.Recursive) {
+		err = s.runSequential(dst, operands)
+	} else {
+		err = s.runConcurrent(dst, operands)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.failed {
+		return ErrFiles
+	}
+	return nil
+}
+
+// Matched reports whether Run selected any line.
+func (s *Search) Matched() bool {
+	return s.matched
+}
+
+// runSequential streams every file straight to dst.
+func (s *Search) runSequential(dst io.Writer, operands []string) error {
+	out := errWriter{w: dst}
+
+	var werr error
+	s.walk(operands, func(t *task) bool {
+		matched, err := s.searchFile(s.fileWriter(out), t.path, t.name)
+		s.matched = s.matched || matched
+		if err != nil {
+			if isWriteError(err) {
+				werr = err
+				return false
+			}
+			s.report(err)
+		}
+		return !(matched && s.cfg.Quiet)
+	}, s.report)
+	return werr
+}
+
+// runConcurrent searches files with a pool of workers. The file next in
+// walk order writes to dst directly, the output of the files after it is
+// spooled until their turn. With -q the first match stops the walk and
+// the files being searched.
+func (s *Search) runConcurrent(dst io.Writer, operands []string) error {
+	n := s.cfg.Workers
+	order := make(chan *task, 4*n)
+	jobs := make(chan *task)
+	stop := make(chan struct{})
+	var once sync.Once
+	halt := func() { once.Do(func() { close(stop) }) }
+	if s.cfg.Quiet {
+		s.stop = stop
+	}
+
+	for range n {
+		go func() {
+			for t := range jobs {
+				matched, err := s.searchFile(t.out, t.path, t.name)
+				if matched && s.cfg.Quiet {
+					halt()
+				}
+				t.done <- result{matched: matched, err: err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(order)
+		defer close(jobs)
+
+		s.walk(operands, func(t *task) bool {
+			t.out = &spool{}
+			t.done = make(chan result, 1)
+			select {
+			case order <- t:
+			case <-stop:
+				return false
+			}
+			select {
+			case jobs <- t:
+			case <-stop:
+				// Every task in order gets a result.
+				t.done <- result{err: errStopped}
+				return false
+			}
+			return true
+		}, func(err error) {
+			t := &task{done: make(chan result, 1)}
+			t.done <- result{err: err}
+			select {
+			case order <- t:
+			case <-stop:
+			}
+		})
+	}()
+
+	// Spill files of the tasks left behind are removed once their
+	// workers are done.
+	defer func() {
+		go func() {
+			for t := range order {
+				t.discard()
+			}
+		}()
+	}()
+
+	out := errWriter{w: dst}
+	for t := range order {
+		if t.out != nil {
+			if err := t.out.attach(s.fileWriter(out)); err != nil {
+				halt()
+				go t.discard()
+				return err
+			}
+		}
+
+		var r result
+		select {
+		case r = <-t.done:
+			t.close()
+		case <-stop:
+			// Only -q stops the search while the results are read.
+			go t.discard()
+			s.matched = true
+			return nil
+		}
+
+		s.matched = s.matched || r.matched
+		switch {
+		case isWriteError(r.err):
+			halt()
+			return r.err
+		case r.err != nil && !errors.Is(r.err, errStopped):
+			s.report(r.err)
+		}
+	}
+	return nil
+}
+
+func (t *task) close() {
+	if t.out != nil {
+		t.out.close()
+	}
+}
+
+// discard waits for the search of t to end and drops its output.
+func (t *task) discard() {
+	<-t.done
+	t.close()
+}
+
+// fileWriter returns the writer for the output of a file. When an earlier
+// file printed lines, the context separator goes before the output, as
+// GNU grep separates the context groups of different files.
+func (s *Search) fileWriter(w io.Writer) io.Writer {
+	return &sepWriter{s: s, w: w, sep: grep.Separator(s.cfg)}
+}
+
+type sepWriter struct {
+	s       *Search
+	w       io.Writer
+	sep     string
+	started bool
+}
+
+func (w *sepWriter) Write(p []byte) (int, error) {
+	if !w.started && len(p) > 0 {
+		w.started = true
+		if w.s.printed && w.sep != "" {
+			if _, err := io.WriteString(w.w, w.sep); err != nil {
+				return 0, err
+			}
+		}
+		w.s.printed = true
+	}
+	return w.w.Write(p)
+}
+
+// searchFile searches a file and reports whether it has selected lines.
+// With -z it is decompressed and tar members are searched one by one, named
+// "archive:member".
+func (s *Search) searchFile(dst io.Writer, path, name string) (bool, error) {
+	var src io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		src = f
+	}
+	if s.stop != nil {
+		src = stopReader{r: src, stop: s.stop}
+	}
+
+	var matched bool
+	var err error
+	if s.cfg.Decompress {
+		matched, err = s.searchArchive(dst, name, src)
+	} else {
+		matched, err = s.grep(dst, s.cfg, name, src)
+	}
+	if err != nil && path != "-" {
+		err = fmt.Errorf("%s: %w", name, err)
+	}
+	return matched, err
+}
+
+func (s *Search) searchArchive(dst io.Writer, name string, src io.Reader) (bool, error) {
+	matched := false
+	err := decompress.Walk(src, func(member string, r io.Reader) error {
+		if member == "" {
+			m, err := s.grep(dst, s.cfg, name, r)
+			matched = matched || m
+			return err
+		}
+
+		// Members are always named, unless -h is given.
+		cfg := *s.cfg
+		cfg.WithFilename = !cfg.NoFilename
+		m, err := s.grep(dst, &cfg, name+":"+member, r)
+		matched = matched || m
+		if err != nil {
+			return fmt.Errorf("%s: %w", member, err)
+		}
+		if m && s.cfg.Quiet {
+			return errStopped
+		}
+		return nil
+	})
+	if matched && s.cfg.Quiet && errors.Is(err, errStopped) {
+		err = nil
+	}
+	return matched, err
+}
+
+func (s *Search) grep(dst io.Writer, cfg *config.Config, name string, src io.Reader) (bool, error) {
+	g := grep.NewFile(cfg, name, src, s.match)
+	if err := g.Run(dst); err != nil {
+		return false, err
+	}
+	return g.Matches() > 0, nil
+}
+
+// report records a file error and prints it unless -s is given. The exit
+// status reflects the error either way.
+func (s *Search) report(err error) {
+	s.failed = true
+	if !s.cfg.NoMessages {
+		fmt.Fprintf(s.stderr, "error: %v\n", err)
+	}
+}
+
+// stopReader fails once stop is closed, so that files still being searched
+// are given up after -q found a match elsewhere.
+type stopReader struct {
+	r    io.Reader
+	stop <-chan struct{}
+}
+
+func (s stopReader) Read(p []byte) (int, error) {
+	select {
+	case <-s.stop:
+		return 0, errStopped
+	default:
+	}
+	return s.r.Read(p)
+}
+
+// walk calls visit for every file to search, in a stable order: operands
+// as listed, directory entries sorted by name. It stops when visit
+// returns false. Errors are passed to fail.
+func (s *Search) walk(operands []string, visit func(*task) bool, fail func(error)) {
+	for _, op := range operands {
+		if op == "-" {
+			if !visit(&task{path: "-", name: grep.StdinName}) {
+				return
+			}
+			continue
+		}
+
+		info, err := os.Stat(op)
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		if !info.IsDir() {
+			if s.selected(filepath.Base(op)) && !visit(&task{path: op, name: op}) {
+				return
+			}
+			continue
+		}
+
+		if !s.cfg.Recursive {
+			fail(fmt.Errorf("%s: is a directory", op))
+			continue
+		}
+
+		w := &walker{s: s, visit: visit, fail: fail, ignore: ignore.New()}
+		if !w.walkDir(op, ".", []os.FileInfo{info}) {
+			return
+		}
+	}
+}
+
+// selected applies --include and --exclude to a file base name.
+func (s *Search) selected(name string) bool {
+	if len(s.cfg.Include) > 0 && !matchAny(s.cfg.Include, name) {
+		return false
+	}
+	return !matchAny(s.cfg.Exclude, name)
+}
+
+type walker struct {
+	s      *Search
+	visit  func(*task) bool
+	fail   func(error)
+	ignore *ignore.Matcher
+}
+
+// walkDir walks dir, whose path relative to the operand is rel. The
+// ancestors are used to detect symlink loops with -R.
+func (w *walker) walkDir(dir, rel string, ancestors []os.FileInfo) bool {
+	cfg := w.s.cfg
+
+	if !cfg.NoIgnore {
+		if err := w.ignore.Load(dir, rel); err != nil {
+			w.fail(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.fail(err)
+		return true
+	}
+
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		r := path.Join(rel, e.Name())
+
+		info, err := e.Info()
+		if err != nil {
+			w.fail(err)
+			continue
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !cfg.Dereference {
+				continue
+			}
+			if info, err = os.Stat(p); err != nil {
+				w.fail(err)
+				continue
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			if matchAny(cfg.ExcludeDir, e.Name()) {
+				continue
+			}
+			if !cfg.NoIgnore && (e.Name() == ".git" || w.ignore.Ignored(r, true)) {
+				continue
+			}
+			if loops(info, ancestors) {
+				w.fail(fmt.Errorf("%s: recursive directory loop", p))
+				continue
+			}
+			if !w.walkDir(p, r, append(ancestors, info)) {
+				return false
+			}
+		case info.Mode().IsRegular():
+			if !cfg.NoIgnore && w.ignore.Ignored(r, false) {
+				continue
+			}
+			if w.s.selected(e.Name()) && !w.visit(&task{path: p, name: p}) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func loops(info os.FileInfo, ancestors []os.FileInfo) bool {
+	for _, a := range ancestors {
+		if os.SameFile(info, a) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(globs []string, name string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeError marks errors of the output writer, which stop the search
+// instead of being reported per file.
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string { return e.err.Error() }
+func (e *writeError) Unwrap() error { return e.err }
+
+// errWriter marks the errors of w as write errors.
+type errWriter struct {
+	w io.Writer
+}
+
+func (e errWriter) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	if err != nil {
+		err = &writeError{err: err}
+	}
+	return n, err
+}
+
+func isWriteError(err error) bool {
+	var we *writeError
+	return errors.As(err, &we)
+}
@@ -0,0 +1,486 @@
+package search
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wb-grep/internal/config"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestSearch_Run(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		".gitignore":     "*.log\nignored/\n",
+		"a.txt":          "apple\nbanana\n",
+		"b.log":          "apple\n",
+		"bin.dat":        "apple\x00\n",
+		"empty.txt":      "pear\n",
+		"ignored/x.txt":  "apple\n",
+		"sub/c.txt":      "apple pie\n",
+		"sub/z.md":       "apple\n",
+		"vendor/d.txt":   "apple\n",
+		"sub/.gitignore": "z.md\n",
+	})
+	t.Chdir(root)
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{
+			name: "Recursive with gitignore",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, WithFilename: true},
+			want: "a.txt:apple\nBinary file bin.dat matches\nsub/c.txt:apple pie\nvendor/d.txt:apple\n",
+		},
+		{
+			name: "No ignore",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, WithFilename: true, NoIgnore: true, SkipBinary: true},
+			want: "a.txt:apple\nb.log:apple\nignored/x.txt:apple\nsub/c.txt:apple pie\nsub/z.md:apple\nvendor/d.txt:apple\n",
+		},
+		{
+			name: "Include and exclude-dir",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, WithFilename: true, Include: []string{"*.txt"}, ExcludeDir: []string{"vendor"}},
+			want: "a.txt:apple\nsub/c.txt:apple pie\n",
+		},
+		{
+			name: "Exclude",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, WithFilename: true, Exclude: []string{"a.*", "*.dat"}},
+			want: "sub/c.txt:apple pie\nvendor/d.txt:apple\n",
+		},
+		{
+			name: "Files with matches",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, FilesWithMatches: true, Text: true},
+			want: "a.txt\nbin.dat\nsub/c.txt\nvendor/d.txt\n",
+		},
+		{
+			name: "Files without match",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, FilesWithoutMatch: true},
+			want: ".gitignore\nempty.txt\nsub/.gitignore\n",
+		},
+		{
+			name: "Several files with line numbers",
+			cfg:  config.Config{Pattern: "a", Files: []string{"a.txt", "empty.txt"}, WithFilename: true, LineNum: true},
+			want: "a.txt:1:apple\na.txt:2:banana\nempty.txt:1:pear\n",
+		},
+		{
+			name: "No filename",
+			cfg:  config.Config{Pattern: "apple", Files: []string{"a.txt", "sub/c.txt"}},
+			want: "apple\napple pie\n",
+		},
+		{
+			name: "Count per file",
+			cfg:  config.Config{Pattern: "a", Files: []string{"a.txt", "sub/c.txt"}, WithFilename: true, CountOnly: true},
+			want: "a.txt:2\nsub/c.txt:1\n",
+		},
+		{
+			name: "Context separator between files",
+			cfg:  config.Config{Pattern: "apple", Files: []string{"a.txt", "empty.txt", "sub/c.txt"}, WithFilename: true, After: 1},
+			want: "a.txt:apple\na.txt-banana\n--\nsub/c.txt:apple pie\n",
+		},
+		{
+			name: "Recursive context separator",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, SkipBinary: true, Before: 1, Include: []string{"*.txt"}},
+			want: "apple\n--\napple pie\n--\napple\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			cfg := tt.cfg
+			cfg.Workers = workers
+
+			var dst, stderr bytes.Buffer
+			if err := New(&cfg, &stderr).Run(&dst); err != nil {
+				t.Errorf("%s/%d: Run() error = %v, stderr %q", tt.name, workers, err, stderr.String())
+				continue
+			}
+			if dst.String() != tt.want {
+				t.Errorf("%s/%d: got\n%q\nwant\n%q", tt.name, workers, dst.String(), tt.want)
+			}
+		}
+	}
+}
+
+// TestSearch_RunSpooled searches files whose output is larger than what
+// is kept in memory while they wait for their turn.
+func TestSearch_RunSpooled(t *testing.T) {
+	files := map[string]string{}
+	var want strings.Builder
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		lines := strings.Repeat(name+" match\n", 2*spoolLimit/len(name+" match\n"))
+		files[name] = lines
+		want.WriteString(lines)
+	}
+	t.Chdir(writeTree(t, files))
+
+	cfg := config.Config{Pattern: "match", Recursive: true, Workers: 4}
+	var dst bytes.Buffer
+	if err := New(&cfg, io.Discard).Run(&dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.String() != want.String() {
+		t.Errorf("output of %d bytes differs from the files of %d bytes", dst.Len(), want.Len())
+	}
+}
+
+func TestSearch_RunErrors(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": "apple\n", "dir/b.txt": "apple\n"})
+	t.Chdir(root)
+
+	for _, workers := range []int{1, 4} {
+		cfg := config.Config{Pattern: "apple", Files: []string{"missing.txt", "a.txt", "dir"}, WithFilename: true, Workers: workers}
+
+		var dst, stderr bytes.Buffer
+		err := New(&cfg, &stderr).Run(&dst)
+		if !errors.Is(err, ErrFiles) {
+			t.Errorf("workers %d: Run() error = %v, want %v", workers, err, ErrFiles)
+		}
+		if dst.String() != "a.txt:apple\n" {
+			t.Errorf("workers %d: output = %q", workers, dst.String())
+		}
+		msgs := stderr.String()
+		if !strings.Contains(msgs, "missing.txt") || !strings.Contains(msgs, "dir: is a directory") {
+			t.Errorf("workers %d: stderr = %q", workers, msgs)
+		}
+	}
+}
+
+func TestSearch_RunBadPattern(t *testing.T) {
+	cfg := config.Config{Pattern: "(", Workers: 1}
+	if err := New(&cfg, &bytes.Buffer{}).Run(&bytes.Buffer{}); err == nil || errors.Is(err, ErrFiles) {
+		t.Errorf("Run() error = %v, want compile error", err)
+	}
+}
+
+func TestSearch_RunQuiet(t *testing.T) {
+	files := map[string]string{}
+	for i := range 50 {
+		files[fmt.Sprintf("d/f%02d.txt", i)] = "pear\n"
+	}
+	files["d/m.txt"] = "apple\n"
+	root := writeTree(t, files)
+	t.Chdir(root)
+
+	for _, workers := range []int{1, 4} {
+		for _, pattern := range []string{"apple", "plum"} {
+			cfg := config.Config{Pattern: pattern, Recursive: true, Quiet: true, Workers: workers}
+
+			var dst, stderr bytes.Buffer
+			s := New(&cfg, &stderr)
+			if err := s.Run(&dst); err != nil {
+				t.Errorf("%s/%d: Run() error = %v", pattern, workers, err)
+			}
+			if dst.Len() != 0 || stderr.Len() != 0 {
+				t.Errorf("%s/%d: output %q, stderr %q, want none", pattern, workers, dst.String(), stderr.String())
+			}
+			if want := pattern == "apple"; s.Matched() != want {
+				t.Errorf("%s/%d: Matched() = %v, want %v", pattern, workers, s.Matched(), want)
+			}
+		}
+	}
+}
+
+func TestSearch_RunNoMessages(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": "apple\n"})
+	t.Chdir(root)
+
+	for _, workers := range []int{1, 4} {
+		cfg := config.Config{Pattern: "apple", Files: []string{"missing.txt", "a.txt"}, NoMessages: true, Workers: workers}
+
+		var dst, stderr bytes.Buffer
+		s := New(&cfg, &stderr)
+		if err := s.Run(&dst); !errors.Is(err, ErrFiles) {
+			t.Errorf("workers %d: Run() error = %v, want %v", workers, err, ErrFiles)
+		}
+		if stderr.Len() != 0 {
+			t.Errorf("workers %d: stderr = %q, want none", workers, stderr.String())
+		}
+		if dst.String() != "apple\n" || !s.Matched() {
+			t.Errorf("workers %d: output = %q, Matched() = %v", workers, dst.String(), s.Matched())
+		}
+	}
+}
+
+func TestSearch_RunDecompress(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("apple\npear\n"))
+	zw.Close()
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	for _, f := range []struct{ name, body string }{
+		{"x.log", "pear\n"},
+		{"y.log", "green apple\n"},
+	} {
+		tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.body))})
+		tw.Write([]byte(f.body))
+	}
+	tw.Close()
+
+	root := writeTree(t, map[string]string{
+		"a.log.gz":  gz.String(),
+		"logs.tar":  archive.String(),
+		"plain.txt": "apple\n",
+	})
+	t.Chdir(root)
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{
+			name: "Single compressed file",
+			cfg:  config.Config{Pattern: "apple", Files: []string{"a.log.gz"}},
+			want: "apple\n",
+		},
+		{
+			name: "Tar members are named",
+			cfg:  config.Config{Pattern: "apple", Files: []string{"logs.tar"}, LineNum: true},
+			want: "logs.tar:y.log:1:green apple\n",
+		},
+		{
+			name: "Recursive",
+			cfg:  config.Config{Pattern: "apple", Recursive: true, WithFilename: true},
+			want: "a.log.gz:apple\nlogs.tar:y.log:green apple\nplain.txt:apple\n",
+		},
+		{
+			name: "Files with matches",
+			cfg:  config.Config{Pattern: "pear", Recursive: true, FilesWithMatches: true},
+			want: "a.log.gz\nlogs.tar:x.log\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, workers := range []int{1, 4} {
+			cfg := tt.cfg
+			cfg.Decompress = true
+			cfg.Workers = workers
+
+			var dst, stderr bytes.Buffer
+			if err := New(&cfg, &stderr).Run(&dst); err != nil {
+				t.Errorf("%s/%d: Run() error = %v, stderr %q", tt.name, workers, err, stderr.String())
+				continue
+			}
+			if dst.String() != tt.want {
+				t.Errorf("%s/%d: got\n%q\nwant\n%q", tt.name, workers, dst.String(), tt.want)
+			}
+		}
+	}
+}
+
+// TestSearch_RunBigFile searches a file larger than the chunks that the
+// fixed string fast path reads.
+func TestSearch_RunBigFile(t *testing.T) {
+	const lines = 1 << 20 / 12
+	big := strings.Repeat("filler line\n", lines) + "needle at the end"
+	root := writeTree(t, map[string]string{"big.txt": big})
+	t.Chdir(root)
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{
+			name: "fast path",
+			cfg:  config.Config{Pattern: "needle", Fixed: true, LineNum: true},
+			want: fmt.Sprintf("%d:needle at the end\n", lines+1),
+		},
+		{
+			name: "line by line",
+			cfg:  config.Config{Pattern: "NEEDLE", Fixed: true, IgnoreCase: true, CountOnly: true},
+			want: "1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Files = []string{"big.txt"}
+			tt.cfg.Workers = 1
+
+			var dst bytes.Buffer
+			if err := New(&tt.cfg, io.Discard).Run(&dst); err != nil {
+				t.Fatal(err)
+			}
+			if dst.String() != tt.want {
+				t.Errorf("output = %q, want %q", dst.String(), tt.want)
+			}
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be read while Follow writes it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor polls until b holds want.
+func waitFor(t *testing.T, b *syncBuffer, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.String() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("output = %q, want %q", b.String(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSearch_Follow(t *testing.T) {
+	followInterval = time.Millisecond
+	root := writeTree(t, map[string]string{"a.log": "old error\n", "b.log": ""})
+	t.Chdir(root)
+	appendTo := func(name, s string) {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Config{Pattern: "error", Files: []string{"a.log", "b.log", "missing.log"}, After: 1, WithFilename: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	var dst syncBuffer
+	var stderr bytes.Buffer
+	s := New(&cfg, &stderr)
+	done := make(chan error, 1)
+	go func() { done <- s.Follow(ctx, &dst) }()
+
+	want := "a.log:old error\n"
+	waitFor(t, &dst, want)
+
+	appendTo("b.log", "b error\n")
+	want += "b.log:b error\n"
+	waitFor(t, &dst, want)
+
+	// The trailing context of a match is printed as lines arrive.
+	appendTo("a.log", "ok 1\nok 2\n")
+	want += "a.log-ok 1\n"
+	waitFor(t, &dst, want)
+
+	// Rotation.
+	if err := os.Rename("a.log", "a.log.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("a.log", []byte("new error\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want += "--\na.log:new error\n"
+	waitFor(t, &dst, want)
+
+	cancel()
+	if err := <-done; !errors.Is(err, ErrFiles) {
+		t.Errorf("Follow() error = %v, want %v", err, ErrFiles)
+	}
+	if !s.Matched() || !strings.Contains(stderr.String(), "missing.log") {
+		t.Errorf("Matched() = %v, stderr = %q", s.Matched(), stderr.String())
+	}
+	if dst.String() != want {
+		t.Errorf("output after cancel = %q, want %q", dst.String(), want)
+	}
+}
+
+// TestSearch_FollowRestart checks that line numbers, byte offsets and the
+// before context start over with a rotated or truncated file.
+func TestSearch_FollowRestart(t *testing.T) {
+	followInterval = time.Millisecond
+	t.Chdir(writeTree(t, map[string]string{"a.log": "x\nerror one\n"}))
+
+	cfg := config.Config{Pattern: "error", Files: []string{"a.log"}, LineNum: true, ByteOffset: true, Before: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var dst syncBuffer
+	done := make(chan error, 1)
+	go func() { done <- New(&cfg, io.Discard).Follow(ctx, &dst) }()
+
+	want := "1-0-x\n2:2:error one\n"
+	waitFor(t, &dst, want)
+
+	// The line before the rotation is not context of the new file.
+	if err := os.WriteFile("a.log.1", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile("a.log", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("old\n")
+	f.Close()
+	time.Sleep(10 * followInterval)
+	if err := os.Rename("a.log", "a.log.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("a.log", []byte("error two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want += "--\n1:0:error two\n"
+	waitFor(t, &dst, want)
+
+	// Truncation, noticed as the file is now shorter than what was read.
+	if err := os.WriteFile("a.log", []byte("error 3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want += "--\n1:0:error 3\n"
+	waitFor(t, &dst, want)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Follow() error = %v", err)
+	}
+}
+
+func TestSearch_FollowQuiet(t *testing.T) {
+	followInterval = time.Millisecond
+	root := writeTree(t, map[string]string{"a.log": "", "b.log": "error\n"})
+	t.Chdir(root)
+
+	cfg := config.Config{Pattern: "error", Files: []string{"a.log", "b.log"}, Quiet: true}
+	s := New(&cfg, io.Discard)
+	if err := s.Follow(context.Background(), io.Discard); err != nil || !s.Matched() {
+		t.Errorf("Follow() error = %v, Matched() = %v", err, s.Matched())
+	}
+}
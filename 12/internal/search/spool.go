@@ -0,0 +1,78 @@
+package search
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// spoolLimit is how much output of a file is kept in memory while files
+// before it are still being written. The rest goes to a temporary file.
+const spoolLimit = 1 << 20
+
+// spool holds the output of a file searched ahead of its turn. Once the
+// file is next in order, attach writes out what was held and sends the
+// rest of the output straight on, so that memory stays bounded however
+// large the files are.
+type spool struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	file *os.File  // spill file, once buf would grow past spoolLimit
+	dst  io.Writer // set by attach
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.dst != nil:
+		return s.dst.Write(p)
+	case s.file == nil && s.buf.Len()+len(p) > spoolLimit:
+		f, err := os.CreateTemp("", "wb-grep-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		if _, err := s.file.Write(s.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.buf = bytes.Buffer{}
+	}
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+// attach writes the output held so far to dst and passes later writes
+// through to it.
+func (s *spool) attach(dst io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dst = dst
+	if s.file != nil {
+		if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.Copy(dst, s.file)
+		return err
+	}
+	_, err := dst.Write(s.buf.Bytes())
+	s.buf = bytes.Buffer{}
+	return err
+}
+
+// close removes the spill file. The spool must not be written any more.
+func (s *spool) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+		s.file = nil
+	}
+}
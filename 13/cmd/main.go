@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"os"
+	"syscall"
 
 	"wb-cut/internal/config"
 	"wb-cut/internal/cut"
@@ -11,9 +14,20 @@ import (
 func main() {
 	cfg := config.InitConfig()
 
+	out := bufio.NewWriterSize(os.Stdout, 64*1024)
 	c := cut.New(cfg)
-	if err := c.Run(os.Stdin, os.Stdout); err != nil {
+	err := c.RunFiles(os.Stdin, out, os.Stderr)
+	if ferr := out.Flush(); err == nil && ferr != nil {
+		err = fmt.Errorf("%w: %w", cut.ErrWrite, ferr)
+	}
+
+	switch {
+	case err == nil:
+		return
+	case errors.Is(err, syscall.EPIPE):
+		// The reader went away, there is no one to tell.
+	case !errors.Is(err, cut.ErrFiles):
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
 	}
+	os.Exit(1)
 }
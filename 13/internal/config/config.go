@@ -4,25 +4,124 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"unicode/utf8"
 )
 
 type Config struct {
+	Files     []string // operands, "-" for stdin
 	Fields    string
+	Bytes     string // -b LIST, select bytes
+	Chars     string // -c LIST, select characters, UTF-8 aware
+	NoSplit   bool   // -n, with -b do not split multibyte characters
 	Delimiter string
 	Separated bool
+
+	RegexDelimiter string // --regex-delimiter RE, fields are separated by matches of RE
+	Whitespace     bool   // -w, fields are separated by runs of blanks, leading blanks ignored
+
+	Complement      bool    // --complement, select the positions not in the list
+	OutputDelimiter *string // --output-delimiter STRING, nil for the default
+	ZeroTerminated  bool    // -z, records end with NUL instead of a newline
+	Reorder         bool    // --reorder, output fields in the listed order, repeats included
+
+	Names string // -F NAMES, fields to select by the names in the first line
+	CSV   bool   // --csv, comma separated values with RFC 4180 quoting
+	TSV   bool   // --tsv, like --csv with tabs
+
+	Format string // --format text|csv|json, how selected fields are written
+	Header bool   // --header, the first line names the keys of JSON objects
 }
 
 func InitConfig() *Config {
 	cfg := Config{}
 	flag.StringVar(&cfg.Fields, "f", "", "fields to select (e.g. 1,3-5)")
+	flag.StringVar(&cfg.Bytes, "b", "", "bytes to select (e.g. 1,3-5)")
+	flag.StringVar(&cfg.Chars, "c", "", "characters to select (e.g. 1,3-5)")
+	flag.BoolVar(&cfg.NoSplit, "n", false, "with -b, do not split multibyte characters")
 	flag.StringVar(&cfg.Delimiter, "d", "\t", "delimiter character")
 	flag.BoolVar(&cfg.Separated, "s", false, "only output lines containing delimiter")
+	flag.StringVar(&cfg.RegexDelimiter, "regex-delimiter", "", "separate fields by matches of a regular expression")
+	flag.BoolVar(&cfg.Whitespace, "w", false, "separate fields by runs of spaces and tabs, ignoring leading ones")
+	flag.BoolVar(&cfg.Complement, "complement", false, "select the bytes, characters or fields not in the list")
+	outputDelimiter := flag.String("output-delimiter", "", "separate output fields and ranges with STRING")
+	flag.BoolVar(&cfg.ZeroTerminated, "z", false, "records end with NUL instead of a newline")
+	flag.BoolVar(&cfg.Reorder, "reorder", false, "with -f, output fields in the listed order, repeats included")
+	flag.StringVar(&cfg.Names, "F", "", "fields to select by the names in the first line (e.g. name,email)")
+	flag.BoolVar(&cfg.CSV, "csv", false, "parse and quote fields as CSV (RFC 4180)")
+	flag.BoolVar(&cfg.TSV, "tsv", false, "like --csv, with tabs instead of commas")
+	flag.StringVar(&cfg.Format, "format", "text", "output format of fields: text, csv or json")
+	flag.BoolVar(&cfg.Header, "header", false, "take the first line as names, --format json then writes objects")
 	flag.Parse()
 
-	if cfg.Fields == "" {
-		fmt.Fprintln(os.Stderr, "usage: cut -f fields [-d delimiter] [-s]")
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "output-delimiter" {
+			cfg.OutputDelimiter = outputDelimiter
+		}
+	})
+
+	lists := 0
+	for _, list := range []string{cfg.Fields, cfg.Bytes, cfg.Chars, cfg.Names} {
+		if list != "" {
+			lists++
+		}
+	}
+	if lists == 0 {
+		fmt.Fprintln(os.Stderr, "usage: cut -b list | -c list | -f fields | -F names [-d delimiter] [-s] [-n] [file ...]")
+		os.Exit(1)
+	}
+	if lists > 1 {
+		fmt.Fprintln(os.Stderr, "only one of -b, -c, -f and -F may be specified")
+		os.Exit(1)
+	}
+	fields := cfg.Fields != "" || cfg.Names != ""
+	if cfg.Reorder && (!fields || cfg.Complement) {
+		fmt.Fprintln(os.Stderr, "--reorder works only with -f or -F and without --complement")
+		os.Exit(1)
+	}
+	if (cfg.Whitespace || cfg.RegexDelimiter != "") && (!fields || cfg.Whitespace && cfg.RegexDelimiter != "") {
+		fmt.Fprintln(os.Stderr, "use one of -w and --regex-delimiter, only with -f or -F")
+		os.Exit(1)
+	}
+	if cfg.Delimiter == "" {
+		fmt.Fprintln(os.Stderr, "the delimiter must not be empty")
+		os.Exit(1)
+	}
+	if cfg.Separated && !fields {
+		fmt.Fprintln(os.Stderr, "-s makes sense only with -f or -F")
+		os.Exit(1)
+	}
+
+	switch cfg.Format {
+	case "text":
+	case "csv", "json":
+		if !fields || cfg.ZeroTerminated {
+			fmt.Fprintln(os.Stderr, "--format csv and json work only with -f or -F and without -z")
+			os.Exit(1)
+		}
+		if cfg.OutputDelimiter != nil && utf8.RuneCountInString(*cfg.OutputDelimiter) != 1 {
+			fmt.Fprintf(os.Stderr, "--output-delimiter must be a single character with --format %s\n", cfg.Format)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "invalid value for --format: %q\n", cfg.Format)
 		os.Exit(1)
 	}
 
+	if cfg.CSV || cfg.TSV {
+		switch {
+		case cfg.CSV && cfg.TSV:
+			fmt.Fprintln(os.Stderr, "only one of --csv and --tsv may be specified")
+			os.Exit(1)
+		case !fields || cfg.ZeroTerminated || cfg.Whitespace || cfg.RegexDelimiter != "":
+			fmt.Fprintln(os.Stderr, "--csv and --tsv work only with -f or -F and without -z, -w and --regex-delimiter")
+			os.Exit(1)
+		case cfg.OutputDelimiter != nil && utf8.RuneCountInString(*cfg.OutputDelimiter) != 1:
+			fmt.Fprintln(os.Stderr, "--output-delimiter must be a single character with --csv and --tsv")
+			os.Exit(1)
+		}
+	}
+
+	cfg.Files = flag.Args()
+
 	return &cfg
 }
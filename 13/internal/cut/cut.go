@@ -3,13 +3,26 @@ package cut
 import (
 	"bufio"
 	"bytes"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"io/fs"
+	"os"
+	"slices"
 	"strings"
+	"unicode/utf8"
 	"wb-cut/internal/config"
 )
 
+// ErrWrite wraps the errors of writing the output, which end the whole run
+// rather than the current file.
+var ErrWrite = errors.New("write error")
+
+// ErrFiles is returned by RunFiles when some files could not be cut. The
+// reasons are reported to the error writer as they happen.
+var ErrFiles = errors.New("some files could not be cut")
+
 type Cut struct {
 	cfg *config.Config
 }
@@ -18,78 +31,412 @@ func New(cfg *config.Config) *Cut {
 	return &Cut{cfg: cfg}
 }
 
+// RunFiles cuts the files of the configuration one after another, "-" and
+// no files at all meaning stdin. Files that fail are reported to stderr
+// and the rest are still cut, while errors of the list and of writing to
+// dest end the run.
+func (c *Cut) RunFiles(stdin io.Reader, dest, stderr io.Writer) error {
+	if _, err := c.selection(); err != nil {
+		return err
+	}
+
+	files := c.cfg.Files
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	failed := false
+	for _, name := range files {
+		err := c.runFile(name, stdin, dest)
+		if errors.Is(err, ErrWrite) {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			failed = true
+		}
+	}
+
+	if failed {
+		return ErrFiles
+	}
+	return nil
+}
+
+func (c *Cut) runFile(name string, stdin io.Reader, dest io.Writer) error {
+	if name == "-" {
+		return c.Run(stdin, dest)
+	}
+
+	// Errors of the file system name the file already.
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = c.Run(f, dest)
+	var pathErr *fs.PathError
+	if err != nil && !errors.Is(err, ErrWrite) && !errors.As(err, &pathErr) {
+		err = fmt.Errorf("%s: %w", name, err)
+	}
+	return err
+}
+
+// Run cuts every line of source and writes the result to dest. Bytes
+// (-b) and characters (-c) are selected from the whole line, fields (-f)
+// are split by the delimiter, a regular expression or runs of blanks.
+// With -z records end with NUL instead of a newline. Fields selected by
+// name (-F) are looked up in the first line. Lines may be of any length.
 func (c *Cut) Run(source io.Reader, dest io.Writer) error {
-	fieldMap, err := parseFields(c.cfg.Fields)
+	if c.cfg.CSV || c.cfg.TSV {
+		return c.runCSV(source, dest)
+	}
+
+	selected, err := c.selection()
 	if err != nil {
 		return err
 	}
+	named := c.cfg.Names != ""
+
+	fields := c.cfg.Bytes == "" && c.cfg.Chars == ""
+	var sep separator
+	if fields {
+		if sep, err = c.newSeparator(); err != nil {
+			return err
+		}
+	}
+	// Fields are joined with the input delimiter by default, or with a tab
+	// when separators vary. Bytes and characters are not separated.
+	var outDelimiter []byte
+	switch {
+	case !fields:
+	case c.cfg.Whitespace || c.cfg.RegexDelimiter != "":
+		outDelimiter = []byte{'\t'}
+	default:
+		outDelimiter = []byte(c.cfg.Delimiter)
+	}
+	if c.cfg.OutputDelimiter != nil {
+		outDelimiter = []byte(*c.cfg.OutputDelimiter)
+	}
 
-	delimiter := []byte(c.cfg.Delimiter)
-	scanner := bufio.NewScanner(source)
+	end := byte('\n')
+	if c.cfg.ZeroTerminated {
+		end = 0
+	}
+	r := bufio.NewReaderSize(source, 64*1024)
 
-	for scanner.Scan() {
-		line := scanner.Bytes()
+	var enc *encoder
+	if c.structured() {
+		enc = c.newEncoder(',')
+	}
+	header := c.cfg.Header
 
-		if !bytes.Contains(line, delimiter) {
-			if c.cfg.Separated {
+	for {
+		line, err := readRecord(r, end)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var out []byte
+		switch {
+		case c.cfg.Bytes != "":
+			out = cutBytes(line, selected, c.cfg.NoSplit, outDelimiter)
+		case c.cfg.Chars != "":
+			out = cutChars(line, selected, outDelimiter)
+		default:
+			// Blanks before the first field are not a separator with -w.
+			record := line
+			if c.cfg.Whitespace {
+				record = bytes.TrimLeft(line, " \t")
+			}
+
+			if named {
+				var header []string
+				for _, name := range sep.split(record) {
+					header = append(header, string(name))
+				}
+				if selected, err = c.resolve(header); err != nil {
+					return err
+				}
+				named = false
+			}
+
+			start, _ := sep(record)
+			if start < 0 && c.cfg.Separated {
 				continue
 			}
-			dest.Write(line)
-			dest.Write([]byte{'\n'})
-			continue
-		}
 
-		parts := bytes.Split(line, delimiter)
-		var outParts [][]byte
+			if enc != nil {
+				values := []string{string(line)}
+				if start >= 0 {
+					values = values[:0]
+					for _, f := range sep.split(record) {
+						values = append(values, string(f))
+					}
+				}
+				if out, err = c.encodeRecord(enc, values, selected, header); err != nil {
+					return err
+				}
+				header = false
+				if out == nil {
+					continue
+				}
+				if _, err := dest.Write(out); err != nil {
+					return fmt.Errorf("%w: %w", ErrWrite, err)
+				}
+				continue
+			}
 
-		for i, part := range parts {
-			if fieldMap[i+1] {
-				outParts = append(outParts, part)
+			switch {
+			case start < 0:
+				out = line
+			case c.cfg.Reorder:
+				out = reorderFields(record, selected, sep, outDelimiter)
+			default:
+				out = cutFields(record, selected, sep, outDelimiter)
 			}
 		}
 
-		dest.Write(bytes.Join(outParts, delimiter))
-		dest.Write([]byte{'\n'})
+		if _, err := dest.Write(append(out, end)); err != nil {
+			return fmt.Errorf("%w: %w", ErrWrite, err)
+		}
 	}
+}
 
-	return scanner.Err()
+// structured reports whether fields are written with --format csv or
+// json rather than joined with the output delimiter.
+func (c *Cut) structured() bool {
+	return c.cfg.Format == "csv" || c.cfg.Format == "json"
 }
 
-func parseFields(fieldStr string) (map[int]bool, error) {
-	res := make(map[int]bool)
-	parts := strings.Split(fieldStr, ",")
-	for _, p := range parts {
-		if strings.Contains(p, "-") {
-			// Range
-			ranges := strings.Split(p, "-")
-			if len(ranges) != 2 {
-				return nil, fmt.Errorf("invalid range format: %s", p)
-			}
+// encodeRecord formats the selected fields of a record. A record of a
+// single field, which has no separator, is written whole. With --header
+// the first record names the keys of JSON objects and is not written as a
+// record itself, for which encodeRecord returns nil.
+func (c *Cut) encodeRecord(enc *encoder, values []string, selected ranges, header bool) ([]byte, error) {
+	if header && enc.json {
+		enc.keys = slices.Clone(values)
+		return nil, nil
+	}
+
+	idx := []int{0}
+	if len(values) > 1 {
+		idx = pick(len(values), selected)
+	}
+	return enc.encode(values, idx)
+}
+
+// readRecord returns the next record without its end byte, of any length.
+// A "\r" before a newline is dropped as well.
+func readRecord(r *bufio.Reader, end byte) ([]byte, error) {
+	line, err := r.ReadBytes(end)
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line = bytes.TrimSuffix(line, []byte{end})
+	if end == '\n' {
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+	}
+	return line, nil
+}
+
+// selection parses the list of -b, -c or -f. The names of -F are resolved
+// by the first record instead.
+func (c *Cut) selection() (ranges, error) {
+	list := c.cfg.Fields
+	switch {
+	case c.cfg.Names != "":
+		return nil, nil
+	case c.cfg.Bytes != "":
+		list = c.cfg.Bytes
+	case c.cfg.Chars != "":
+		list = c.cfg.Chars
+	}
+
+	spans, err := parseSpans(list)
+	if err != nil {
+		return nil, err
+	}
+	return c.finish(spans), nil
+}
+
+// resolve turns the -F names into the positions of the fields of header
+// with these names.
+func (c *Cut) resolve(header []string) (ranges, error) {
+	var spans ranges
+	for _, name := range strings.Split(c.cfg.Names, ",") {
+		i := slices.Index(header, name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column: %s", name)
+		}
+		spans = append(spans, span{i + 1, i + 1})
+	}
+	return c.finish(spans), nil
+}
 
-			startStr, endStr := ranges[0], ranges[1]
+// finish merges the spans, unless --reorder keeps them as listed, and
+// applies --complement.
+func (c *Cut) finish(spans ranges) ranges {
+	if c.cfg.Reorder {
+		return spans
+	}
+	spans = spans.merge()
+	if c.cfg.Complement {
+		spans = spans.complement()
+	}
+	return spans
+}
 
-			start, err1 := strconv.Atoi(startStr)
-			end, err2 := strconv.Atoi(endStr)
+// runCSV cuts the fields of CSV or, with --tsv, tab separated records.
+// Quoted fields may hold the delimiter and newlines, and the output is
+// quoted again where needed. Records of a single field are written as
+// they are, or skipped with -s, like lines without the delimiter.
+func (c *Cut) runCSV(source io.Reader, dest io.Writer) error {
+	r := csv.NewReader(source)
+	r.FieldsPerRecord = -1
+	comma := ','
+	if c.cfg.TSV {
+		comma = '\t'
+	}
+	r.Comma = comma
+	enc := c.newEncoder(comma)
 
-			if err1 != nil || err2 != nil {
-				return nil, fmt.Errorf("invalid numbers in range: %s", p)
+	selected, err := c.selection()
+	if err != nil {
+		return err
+	}
+	named := c.cfg.Names != ""
+	header := c.cfg.Header
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if named {
+			if selected, err = c.resolve(record); err != nil {
+				return err
 			}
+			named = false
+		}
+		if len(record) == 1 && c.cfg.Separated {
+			continue
+		}
+
+		out, err := c.encodeRecord(enc, record, selected, header)
+		if err != nil {
+			return err
+		}
+		header = false
+		if out == nil {
+			continue
+		}
+		if _, err := dest.Write(out); err != nil {
+			return fmt.Errorf("%w: %w", ErrWrite, err)
+		}
+	}
+}
+
+// pick returns the indices of the selected fields of a record with n
+// fields, in the order of the spans.
+func pick(n int, spans ranges) []int {
+	var res []int
+	for _, s := range spans {
+		for i := s.lo; i <= min(s.hi, n); i++ {
+			res = append(res, i-1)
+		}
+	}
+	return res
+}
 
-			if start > end {
-				return nil, fmt.Errorf("invalid decreasing range: %s", p)
+// cutFields returns the selected fields of line joined with outDelimiter.
+func cutFields(line []byte, selected ranges, sep separator, outDelimiter []byte) []byte {
+	var out []byte
+	cur := cursor{r: selected}
+	first := true
+	for n := 1; ; n++ {
+		field, rest, found := sep.cutField(line)
+		if cur.span(n) >= 0 {
+			if !first {
+				out = append(out, outDelimiter...)
 			}
+			out = append(out, field...)
+			first = false
+		}
+		if !found || cur.done() {
+			return out
+		}
+		line = rest
+	}
+}
 
-			for i := start; i <= end; i++ {
-				res[i] = true
+// reorderFields returns the fields of line in the order of the spans, as
+// many times as they are listed, joined with outDelimiter.
+func reorderFields(line []byte, spans ranges, sep separator, outDelimiter []byte) []byte {
+	fields := sep.split(line)
+	var out []byte
+	for k, i := range pick(len(fields), spans) {
+		if k > 0 {
+			out = append(out, outDelimiter...)
+		}
+		out = append(out, fields[i]...)
+	}
+	return out
+}
+
+// cutBytes returns the selected bytes of line, with outDelimiter between
+// the bytes of different ranges. With noSplit a multibyte character is
+// kept whole when its last byte is selected and dropped otherwise, as in
+// BSD cut.
+func cutBytes(line []byte, selected ranges, noSplit bool, outDelimiter []byte) []byte {
+	var out []byte
+	cur := cursor{r: selected}
+	last := -1
+	for i := 0; i < len(line) && !cur.done(); {
+		size := 1
+		if noSplit {
+			_, size = utf8.DecodeRune(line[i:])
+		}
+		if s := cur.span(i + size); s >= 0 {
+			if last >= 0 && s != last {
+				out = append(out, outDelimiter...)
 			}
-		} else {
-			// Single number
-			val, err := strconv.Atoi(p)
-			if err != nil {
-				return nil, fmt.Errorf("invalid field number: %s", p)
+			out = append(out, line[i:i+size]...)
+			last = s
+		}
+		i += size
+	}
+	return out
+}
+
+// cutChars returns the selected characters of line, with outDelimiter
+// between the characters of different ranges. Bytes that are not valid
+// UTF-8 count as one character each.
+func cutChars(line []byte, selected ranges, outDelimiter []byte) []byte {
+	var out []byte
+	cur := cursor{r: selected}
+	last := -1
+	for i, n := 0, 1; i < len(line) && !cur.done(); n++ {
+		_, size := utf8.DecodeRune(line[i:])
+		if s := cur.span(n); s >= 0 {
+			if last >= 0 && s != last {
+				out = append(out, outDelimiter...)
 			}
-			res[val] = true
+			out = append(out, line[i:i+size]...)
+			last = s
 		}
+		i += size
 	}
-	return res, nil
+	return out
 }
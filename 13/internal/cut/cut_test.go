@@ -2,7 +2,13 @@ package cut
 
 import (
 	"bytes"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
 	"wb-cut/internal/config"
 )
@@ -69,6 +75,264 @@ func TestCut_Run(t *testing.T) {
 			input:   "a\tb",
 			wantErr: true,
 		},
+		{
+			name:     "Bytes",
+			cfg:      config.Config{Bytes: "1,3-4", Delimiter: "\t"},
+			input:    "abcdef\nab",
+			expected: "acd\na\n",
+		},
+		{
+			name:     "Bytes split multibyte characters",
+			cfg:      config.Config{Bytes: "1-3", Delimiter: "\t"},
+			input:    "привет",
+			expected: "п\xd1\n",
+		},
+		{
+			name:     "Bytes with -n keep characters whole",
+			cfg:      config.Config{Bytes: "1-3,6", Delimiter: "\t", NoSplit: true},
+			input:    "привет\naбв",
+			expected: "пи\naб\n",
+		},
+		{
+			name:     "Characters",
+			cfg:      config.Config{Chars: "2-4", Delimiter: "\t"},
+			input:    "привет\nabcdef\nя",
+			expected: "рив\nbcd\n\n",
+		},
+		{
+			name:     "Characters ignore the delimiter",
+			cfg:      config.Config{Chars: "1,3", Delimiter: ":"},
+			input:    "a:b\nab",
+			expected: "ab\na\n",
+		},
+		{
+			name:     "Characters of invalid UTF-8",
+			cfg:      config.Config{Chars: "2", Delimiter: "\t"},
+			input:    "\xff\xfeб",
+			expected: "\xfe\n",
+		},
+		{
+			name:    "Invalid byte list",
+			cfg:     config.Config{Bytes: "1-x", Delimiter: "\t"},
+			input:   "abc",
+			wantErr: true,
+		},
+		{
+			name:     "Open ranges",
+			cfg:      config.Config{Fields: "-2,4-", Delimiter: ","},
+			input:    "1,2,3,4,5\na,b,c",
+			expected: "1,2,4,5\na,b\n",
+		},
+		{
+			name:     "Huge range",
+			cfg:      config.Config{Fields: "2-1000000000", Delimiter: ","},
+			input:    "1,2,3",
+			expected: "2,3\n",
+		},
+		{
+			name:     "Complement fields",
+			cfg:      config.Config{Fields: "2", Delimiter: ",", Complement: true},
+			input:    "1,2,3,4",
+			expected: "1,3,4\n",
+		},
+		{
+			name:     "Complement characters",
+			cfg:      config.Config{Chars: "2-3", Complement: true, Delimiter: "\t"},
+			input:    "привет",
+			expected: "пвет\n",
+		},
+		{
+			name:     "Output delimiter for fields",
+			cfg:      config.Config{Fields: "1,3", Delimiter: ",", OutputDelimiter: ptr(" | ")},
+			input:    "a,b,c",
+			expected: "a | c\n",
+		},
+		{
+			name:     "Output delimiter between byte ranges",
+			cfg:      config.Config{Bytes: "1-2,3,5-", Delimiter: "\t", OutputDelimiter: ptr(":")},
+			input:    "abcdefg",
+			expected: "ab:c:efg\n",
+		},
+		{
+			name:     "Output delimiter for overlapping ranges",
+			cfg:      config.Config{Chars: "1-3,2-4", Delimiter: "\t", OutputDelimiter: ptr(":")},
+			input:    "abcdef",
+			expected: "abcd\n",
+		},
+		{
+			name:     "Zero terminated records",
+			cfg:      config.Config{Fields: "2", Delimiter: ",", ZeroTerminated: true},
+			input:    "a,b\nc\x00d,e",
+			expected: "b\nc\x00e\x00",
+		},
+		{
+			name:    "Position zero",
+			cfg:     config.Config{Fields: "0-2", Delimiter: "\t"},
+			input:   "a\tb",
+			wantErr: true,
+		},
+		{
+			name:    "Range without endpoints",
+			cfg:     config.Config{Fields: "1,-", Delimiter: "\t"},
+			input:   "a\tb",
+			wantErr: true,
+		},
+		{
+			name:     "Reorder fields",
+			cfg:      config.Config{Fields: "3,1,2", Delimiter: ",", Reorder: true},
+			input:    "a,b,c\n1,2",
+			expected: "c,a,b\n1,2\n",
+		},
+		{
+			name:     "Reorder with repeats and open ranges",
+			cfg:      config.Config{Fields: "2-,1,1", Delimiter: ",", Reorder: true, OutputDelimiter: ptr("\t")},
+			input:    "a,b,c\nx",
+			expected: "b\tc\ta\ta\nx\n",
+		},
+		{
+			name:     "CSV with quoted delimiters",
+			cfg:      config.Config{Fields: "2-3", CSV: true},
+			input:    "a,\"b,1\",c\n\"x\"\"y\",\"multi\nline\",z\n",
+			expected: "\"b,1\",c\n\"multi\nline\",z\n",
+		},
+		{
+			name:     "CSV by header names",
+			cfg:      config.Config{Names: "email,name", CSV: true},
+			input:    "id,name,email\n1,\"Doe, John\",john@example.com\n",
+			expected: "name,email\n\"Doe, John\",john@example.com\n",
+		},
+		{
+			name:     "CSV by header names reordered",
+			cfg:      config.Config{Names: "email,name", CSV: true, Reorder: true},
+			input:    "id,name,email\n1,john,john@example.com\n",
+			expected: "email,name\njohn@example.com,john\n",
+		},
+		{
+			name:     "CSV single field records",
+			cfg:      config.Config{Fields: "2", CSV: true, Separated: true},
+			input:    "a,b\nlonely\n",
+			expected: "b\n",
+		},
+		{
+			name:     "TSV to CSV",
+			cfg:      config.Config{Fields: "1,2", TSV: true, OutputDelimiter: ptr(",")},
+			input:    "a,1\t\"b\tc\"\n",
+			expected: "\"a,1\",b\tc\n",
+		},
+		{
+			name:    "CSV unknown column",
+			cfg:     config.Config{Names: "missing", CSV: true},
+			input:   "id,name\n",
+			wantErr: true,
+		},
+		{
+			name:    "CSV bare quote",
+			cfg:     config.Config{Fields: "1", CSV: true},
+			input:   "a\"b,c\n",
+			wantErr: true,
+		},
+		{
+			name:     "Header names without CSV",
+			cfg:      config.Config{Names: "b", Delimiter: ":"},
+			input:    "a:b\n1:2\n",
+			expected: "b\n2\n",
+		},
+		{
+			name:     "Complement of all fields",
+			cfg:      config.Config{Fields: "1-", Delimiter: ":", Complement: true},
+			input:    "a:b\n",
+			expected: "\n",
+		},
+		{
+			name:     "Whitespace runs",
+			cfg:      config.Config{Fields: "1,3", Whitespace: true},
+			input:    "  PID TTY      TIME CMD\n    1 ?    \t 00:01 init\n",
+			expected: "PID\tTIME\n1\t00:01\n",
+		},
+		{
+			name:     "Whitespace with -s",
+			cfg:      config.Config{Fields: "1", Whitespace: true, Separated: true, OutputDelimiter: ptr(",")},
+			input:    "   alone\na b\n",
+			expected: "a\n",
+		},
+		{
+			name:     "Whitespace line without separators",
+			cfg:      config.Config{Fields: "2", Whitespace: true},
+			input:    "  alone\n",
+			expected: "  alone\n",
+		},
+		{
+			name:     "Regex delimiter",
+			cfg:      config.Config{Fields: "2-", RegexDelimiter: `\s*[;,]\s*`, OutputDelimiter: ptr(",")},
+			input:    "a ; b,c  ,d\nnone\n",
+			expected: "b,c,d\nnone\n",
+		},
+		{
+			name:     "Regex delimiter with header names",
+			cfg:      config.Config{Names: "size", RegexDelimiter: `:+`},
+			input:    "name::size\nx:::10\n",
+			expected: "size\n10\n",
+		},
+		{
+			name:    "Regex delimiter matching empty string",
+			cfg:     config.Config{Fields: "1", RegexDelimiter: `x*`},
+			input:   "a\n",
+			wantErr: true,
+		},
+		{
+			name:     "JSON arrays",
+			cfg:      config.Config{Fields: "1,3", Delimiter: ":", Format: "json"},
+			input:    "a:b:c\n\"q\":x:\\\\\nlonely\nbad\xff:y:z\n",
+			expected: "[\"a\",\"c\"]\n[\"\\\"q\\\"\",\"\\\\\\\\\"]\n[\"lonely\"]\n[\"bad\ufffd\",\"z\"]\n",
+		},
+		{
+			name:     "JSON objects by header",
+			cfg:      config.Config{Fields: "3,1", Delimiter: ",", Format: "json", Header: true},
+			input:    "id,name,email\n1,John,j@example.com\n2\n3,Ann\n",
+			expected: "{\"id\":\"1\",\"email\":\"j@example.com\"}\n{\"id\":\"2\"}\n{\"id\":\"3\"}\n",
+		},
+		{
+			name:     "JSON objects by header names reordered",
+			cfg:      config.Config{Names: "email,id", CSV: true, Format: "json", Header: true, Reorder: true},
+			input:    "id,name,email\n1,\"Doe, J\",j@example.com\n",
+			expected: "{\"email\":\"j@example.com\",\"id\":\"1\"}\n",
+		},
+		{
+			name:    "JSON objects with a repeated field",
+			cfg:     config.Config{Fields: "2,2", Delimiter: ",", Format: "json", Header: true, Reorder: true},
+			input:   "id,name\n1,John\n",
+			wantErr: true,
+		},
+		{
+			name:    "JSON objects with a repeated header name",
+			cfg:     config.Config{Fields: "1-", Delimiter: ",", Format: "json", Header: true},
+			input:   "id,id\n1,2\n",
+			wantErr: true,
+		},
+		{
+			name:     "JSON arrays with a repeated field",
+			cfg:      config.Config{Fields: "2,2", Delimiter: ",", Format: "json", Reorder: true},
+			input:    "1,John\n",
+			expected: "[\"John\",\"John\"]\n",
+		},
+		{
+			name:     "JSON keeps HTML characters",
+			cfg:      config.Config{Fields: "1-", Delimiter: ":", Format: "json", Header: true},
+			input:    "<a>:b&c\nb<c:x>y & z\n",
+			expected: "{\"<a>\":\"b<c\",\"b&c\":\"x>y & z\"}\n",
+		},
+		{
+			name:     "JSON with whitespace delimiter",
+			cfg:      config.Config{Fields: "2-", Whitespace: true, Format: "json"},
+			input:    "  PID  CMD\n  1  init 2\n",
+			expected: "[\"CMD\"]\n[\"init\",\"2\"]\n",
+		},
+		{
+			name:     "CSV output",
+			cfg:      config.Config{Fields: "1-2", Delimiter: ":", Format: "csv", Header: true},
+			input:    "name:note\nx:say \"hi\", then\n",
+			expected: "name,note\nx,\"say \"\"hi\"\", then\"\n",
+		},
 		{
 			name:     "Complex combination",
 			cfg:      config.Config{Fields: "1,4", Delimiter: ",", Separated: false},
@@ -96,3 +360,101 @@ func TestCut_Run(t *testing.T) {
 		})
 	}
 }
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestCut_Selection(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want ranges
+	}{
+		{name: "single", cfg: config.Config{Fields: "1"}, want: ranges{{1, 1}}},
+		{name: "open end", cfg: config.Config{Fields: "3-"}, want: ranges{{3, math.MaxInt}}},
+		{name: "open start", cfg: config.Config{Fields: "-2"}, want: ranges{{1, 2}}},
+		{name: "sorted, adjacent kept apart", cfg: config.Config{Fields: "5,1-2,3"}, want: ranges{{1, 2}, {3, 3}, {5, 5}}},
+		{name: "overlapping merged", cfg: config.Config{Bytes: "2-6,4-8,7-"}, want: ranges{{2, math.MaxInt}}},
+		{name: "repeats merged", cfg: config.Config{Chars: "3,1,3"}, want: ranges{{1, 1}, {3, 3}}},
+		{name: "complement", cfg: config.Config{Fields: "1", Complement: true}, want: ranges{{2, math.MaxInt}}},
+		{name: "complement of open end", cfg: config.Config{Fields: "3-", Complement: true}, want: ranges{{1, 2}}},
+		{name: "complement of merged", cfg: config.Config{Fields: "5,1-2,3", Complement: true}, want: ranges{{4, 4}, {6, math.MaxInt}}},
+		{name: "complement of overlapping", cfg: config.Config{Fields: "2-6,4-8,7-", Complement: true}, want: ranges{{1, 1}}},
+		{name: "complement of all", cfg: config.Config{Fields: "1-", Complement: true}, want: nil},
+		{name: "reorder keeps order", cfg: config.Config{Fields: "3,1-2,3", Reorder: true}, want: ranges{{3, 3}, {1, 2}, {3, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(&tt.cfg).selection()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCut_RunFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(a, []byte("1:2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+
+	cfg := config.Config{Fields: "2", Delimiter: ":", Files: []string{a, missing, "-", a}}
+	var dst, stderr bytes.Buffer
+	err := New(&cfg).RunFiles(strings.NewReader("x:y\n"), &dst, &stderr)
+	if !errors.Is(err, ErrFiles) {
+		t.Errorf("RunFiles() error = %v, want %v", err, ErrFiles)
+	}
+	if dst.String() != "2\ny\n2\n" {
+		t.Errorf("output = %q, want %q", dst.String(), "2\ny\n2\n")
+	}
+	if !strings.Contains(stderr.String(), "missing.txt") {
+		t.Errorf("stderr = %q, want the missing file", stderr.String())
+	}
+}
+
+// failWriter fails every write like a closed pipe.
+type failWriter struct {
+	writes int
+}
+
+func (w *failWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, syscall.EPIPE
+}
+
+func TestCut_RunFilesWriteError(t *testing.T) {
+	for _, cfg := range []config.Config{
+		{Fields: "1", Delimiter: ":", Files: []string{"-", "-"}},
+		{Fields: "1", CSV: true, Files: []string{"-", "-"}},
+	} {
+		var w failWriter
+		var stderr bytes.Buffer
+		err := New(&cfg).RunFiles(strings.NewReader("a:b\nc:d\n"), &w, &stderr)
+		if !errors.Is(err, ErrWrite) || !errors.Is(err, syscall.EPIPE) {
+			t.Errorf("RunFiles() error = %v, want a write error", err)
+		}
+		if w.writes != 1 || stderr.Len() != 0 {
+			t.Errorf("%d writes, stderr = %q, want a single write and no messages", w.writes, stderr.String())
+		}
+	}
+}
+
+func TestCut_RunLongLines(t *testing.T) {
+	long := strings.Repeat("x", 1<<20)
+	cfg := config.Config{Fields: "2", Delimiter: ":"}
+	var dst bytes.Buffer
+	if err := New(&cfg).Run(strings.NewReader("a:"+long+":b\n"), &dst); err != nil {
+		t.Fatal(err)
+	}
+	if dst.String() != long+"\n" {
+		t.Errorf("got %d bytes, want %d", dst.Len(), len(long)+1)
+	}
+}
@@ -0,0 +1,102 @@
+package cut
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// encoder formats the selected fields of records for --format csv and
+// json, one record per line.
+type encoder struct {
+	json bool
+	keys []string // header names for JSON objects, nil for arrays
+	seen map[string]bool
+	buf  bytes.Buffer
+	csv  *csv.Writer
+
+	// str holds a string encoded by enc, which leaves HTML characters
+	// as they are.
+	str bytes.Buffer
+	enc *json.Encoder
+}
+
+// newEncoder returns an encoder for the output format. CSV is separated by
+// comma unless a single character output delimiter is given.
+func (c *Cut) newEncoder(comma rune) *encoder {
+	e := &encoder{json: c.cfg.Format == "json"}
+	e.csv = csv.NewWriter(&e.buf)
+	e.csv.Comma = comma
+	e.enc = json.NewEncoder(&e.str)
+	e.enc.SetEscapeHTML(false)
+	if c.cfg.OutputDelimiter != nil {
+		e.csv.Comma, _ = utf8.DecodeRuneInString(*c.cfg.OutputDelimiter)
+	}
+	return e
+}
+
+// encode returns the fields of values at the indices idx in the output
+// format, ending with a newline. A JSON object may not name the same key
+// twice, as a repeated field or header name would.
+func (e *encoder) encode(values []string, idx []int) ([]byte, error) {
+	e.buf.Reset()
+	if !e.json {
+		out := make([]string, len(idx))
+		for k, i := range idx {
+			out[k] = values[i]
+		}
+		if err := e.csv.Write(out); err != nil {
+			return nil, err
+		}
+		e.csv.Flush()
+		return e.buf.Bytes(), e.csv.Error()
+	}
+
+	open, closing := byte('['), byte(']')
+	if e.keys != nil {
+		open, closing = '{', '}'
+	}
+	if e.seen == nil {
+		e.seen = make(map[string]bool)
+	}
+	clear(e.seen)
+	e.buf.WriteByte(open)
+	for k, i := range idx {
+		if k > 0 {
+			e.buf.WriteByte(',')
+		}
+		if e.keys != nil {
+			key := e.key(i)
+			if e.seen[key] {
+				return nil, fmt.Errorf("duplicate key in JSON object: %s", key)
+			}
+			e.seen[key] = true
+			e.writeString(key)
+			e.buf.WriteByte(':')
+		}
+		e.writeString(values[i])
+	}
+	e.buf.WriteByte(closing)
+	e.buf.WriteByte('\n')
+	return e.buf.Bytes(), nil
+}
+
+// key returns the header name of field i, or its number when the header
+// is shorter than the record.
+func (e *encoder) key(i int) string {
+	if i < len(e.keys) {
+		return e.keys[i]
+	}
+	return strconv.Itoa(i + 1)
+}
+
+// writeString writes s as a JSON string. Bytes that are not valid UTF-8
+// are replaced with U+FFFD.
+func (e *encoder) writeString(s string) {
+	e.str.Reset()
+	e.enc.Encode(s)
+	e.buf.Write(bytes.TrimSuffix(e.str.Bytes(), []byte{'\n'}))
+}
@@ -0,0 +1,126 @@
+package cut
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// span is an inclusive range of 1-based positions. Open ranges like "3-"
+// end at math.MaxInt.
+type span struct {
+	lo, hi int
+}
+
+// ranges is a list of positions as spans. After merge they are sorted and
+// disjoint: overlapping spans are joined, adjacent ones are kept apart, so
+// that the output delimiter of -b and -c goes between them as in GNU cut.
+type ranges []span
+
+// parseSpans parses a list of positions like 1,3-5,7- for -b, -c and -f
+// into spans in the listed order, repeats included.
+func parseSpans(list string) (ranges, error) {
+	var res ranges
+	for _, p := range strings.Split(list, ",") {
+		lo, hi, isRange := strings.Cut(p, "-")
+		if !isRange {
+			hi = lo
+		}
+
+		var s span
+		var err error
+		switch {
+		case isRange && lo == "" && hi == "":
+			return nil, fmt.Errorf("invalid range with no endpoint: %s", p)
+		case lo == "":
+			s.lo = 1
+		default:
+			s.lo, err = parsePosition(lo)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid range: %s: %w", p, err)
+		}
+		if hi == "" {
+			s.hi = math.MaxInt
+		} else if s.hi, err = parsePosition(hi); err != nil {
+			return nil, fmt.Errorf("invalid range: %s: %w", p, err)
+		}
+
+		if s.lo > s.hi {
+			return nil, fmt.Errorf("invalid decreasing range: %s", p)
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func parsePosition(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	switch {
+	case err != nil:
+		return 0, errors.New("not a number")
+	case n < 1:
+		return 0, errors.New("positions are numbered from 1")
+	}
+	return n, nil
+}
+
+// merge sorts the spans and joins overlapping ones.
+func (r ranges) merge() ranges {
+	slices.SortFunc(r, func(a, b span) int {
+		return a.lo - b.lo
+	})
+
+	var res ranges
+	for _, s := range r {
+		if n := len(res); n > 0 && s.lo <= res[n-1].hi {
+			res[n-1].hi = max(res[n-1].hi, s.hi)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// complement returns the positions not in r, for --complement.
+func (r ranges) complement() ranges {
+	var res ranges
+	next := 1
+	for _, s := range r {
+		if s.lo > next {
+			res = append(res, span{next, s.lo - 1})
+		}
+		if s.hi == math.MaxInt {
+			return res
+		}
+		next = s.hi + 1
+	}
+	return append(res, span{next, math.MaxInt})
+}
+
+// cursor looks up positions in increasing order in linear time overall.
+type cursor struct {
+	r ranges
+	i int
+}
+
+// span returns the index of the span that holds position n, or -1. The
+// positions passed must not decrease.
+func (c *cursor) span(n int) int {
+	for c.i < len(c.r) && c.r[c.i].hi < n {
+		c.i++
+	}
+	if c.i < len(c.r) && c.r[c.i].lo <= n {
+		return c.i
+	}
+	return -1
+}
+
+// done reports whether no position after the last one looked up is
+// selected.
+func (c *cursor) done() bool {
+	return c.i == len(c.r)
+}
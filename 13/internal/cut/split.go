@@ -0,0 +1,77 @@
+package cut
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// separator finds the first field separator in a line and returns its
+// start and end, or -1, -1 if there is none.
+type separator func(line []byte) (int, int)
+
+// newSeparator returns the separator of fields: the literal delimiter of
+// -d, a regular expression of --regex-delimiter, or runs of blanks for -w.
+func (c *Cut) newSeparator() (separator, error) {
+	switch {
+	case c.cfg.Whitespace:
+		return regexpSeparator(blanks), nil
+	case c.cfg.RegexDelimiter != "":
+		re, err := regexp.Compile(c.cfg.RegexDelimiter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex delimiter: %w", err)
+		}
+		if re.MatchString("") {
+			return nil, errors.New("the regex delimiter must not match the empty string")
+		}
+		return regexpSeparator(re), nil
+	}
+
+	delimiter := []byte(c.cfg.Delimiter)
+	if len(delimiter) == 0 {
+		return nil, errors.New("empty delimiter")
+	}
+	return func(line []byte) (int, int) {
+		i := bytes.Index(line, delimiter)
+		if i < 0 {
+			return -1, -1
+		}
+		return i, i + len(delimiter)
+	}, nil
+}
+
+var blanks = regexp.MustCompile(`[ \t]+`)
+
+func regexpSeparator(re *regexp.Regexp) separator {
+	return func(line []byte) (int, int) {
+		loc := re.FindIndex(line)
+		if loc == nil {
+			return -1, -1
+		}
+		return loc[0], loc[1]
+	}
+}
+
+// cutField returns the first field of line and the rest after its
+// separator, like bytes.Cut.
+func (sep separator) cutField(line []byte) (field, rest []byte, found bool) {
+	start, end := sep(line)
+	if start < 0 {
+		return line, nil, false
+	}
+	return line[:start], line[end:], true
+}
+
+// split returns all fields of line.
+func (sep separator) split(line []byte) [][]byte {
+	var fields [][]byte
+	for {
+		field, rest, found := sep.cutField(line)
+		fields = append(fields, field)
+		if !found {
+			return fields
+		}
+		line = rest
+	}
+}
@@ -0,0 +1,63 @@
+// Package chans combines done channels: Or is done when any of them is,
+// And when all of them are. Every helper goroutine exits as soon as the
+// result is decided, so nothing leaks when some channels never close.
+package chans
+
+import (
+	"context"
+	"sync"
+)
+
+// Or returns a channel that is closed once any of channels is closed or
+// delivers a value, which is consumed. With no channels it returns nil,
+// which is never ready.
+func Or[T any](channels ...<-chan T) <-chan T {
+	if len(channels) == 0 {
+		return nil
+	}
+
+	res := make(chan T)
+	var once sync.Once
+	for _, c := range channels {
+		go func() {
+			select {
+			case <-c:
+				once.Do(func() { close(res) })
+			case <-res:
+			}
+		}()
+	}
+	return res
+}
+
+// And returns a channel that is closed once all channels are closed.
+// Values sent on them are discarded. With no channels it is closed at once.
+func And[T any](channels ...<-chan T) <-chan T {
+	res := make(chan T)
+	go func() {
+		defer close(res)
+		for _, c := range channels {
+			for range c {
+			}
+		}
+	}()
+	return res
+}
+
+// OrContext returns a context that is canceled once any of channels is
+// closed or delivers a value, when parent is done or when cancel is
+// called. Its helper goroutines exit with the context, so cancel should be
+// called when the context is no longer needed, as with context.WithCancel.
+func OrContext[T any](parent context.Context, channels ...<-chan T) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	for _, c := range channels {
+		go func() {
+			select {
+			case <-c:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancel
+}
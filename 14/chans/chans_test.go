@@ -0,0 +1,282 @@
+package chans
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkLeaks fails the test if goroutines started during it are still
+// running once it is over.
+func checkLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before {
+			if time.Now().After(deadline) {
+				t.Errorf("%d goroutines leaked", runtime.NumGoroutine()-before)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func closed(t *testing.T, c <-chan int) bool {
+	t.Helper()
+	select {
+	case <-c:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+// newChannels returns n buffered channels, so that tests can send a value
+// without waiting for a receiver.
+func newChannels(n int) []chan int {
+	chs := make([]chan int, n)
+	for i := range chs {
+		chs[i] = make(chan int, 1)
+	}
+	return chs
+}
+
+func recv(chs []chan int) []<-chan int {
+	res := make([]<-chan int, len(chs))
+	for i, c := range chs {
+		res[i] = c
+	}
+	return res
+}
+
+func TestOr(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		act    func(chs []chan int)
+		closed bool
+	}{
+		{name: "no channels", n: 0, act: func([]chan int) {}, closed: false},
+		{name: "none closed", n: 3, act: func([]chan int) {}, closed: false},
+		{name: "one closed", n: 1, act: func(chs []chan int) { close(chs[0]) }, closed: true},
+		{name: "last of many closed", n: 100, act: func(chs []chan int) { close(chs[99]) }, closed: true},
+		{name: "last of several batches closed", n: 3*selectBatch + 1, act: func(chs []chan int) { close(chs[3*selectBatch]) }, closed: true},
+		{name: "value sent", n: 3, act: func(chs []chan int) { chs[1] <- 1 }, closed: true},
+		{
+			name: "all closed",
+			n:    5,
+			act: func(chs []chan int) {
+				for _, c := range chs {
+					close(c)
+				}
+			},
+			closed: true,
+		},
+	}
+
+	for _, impl := range []struct {
+		name string
+		or   func(...<-chan int) <-chan int
+	}{
+		{"Or", Or[int]},
+		{"OrSelect", OrSelect[int]},
+	} {
+		for _, tt := range tests {
+			t.Run(impl.name+"/"+tt.name, func(t *testing.T) {
+				checkLeaks(t)
+				chs := newChannels(tt.n)
+				res := impl.or(recv(chs)...)
+				tt.act(chs)
+
+				if got := closed(t, res); got != tt.closed {
+					t.Errorf("%s() closed = %v, want %v", impl.name, got, tt.closed)
+				}
+				if !tt.closed && tt.n > 0 {
+					// Helpers wait until the result is decided.
+					close(chs[0])
+					if !closed(t, res) {
+						t.Errorf("%s() not closed after a channel was", impl.name)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestOr_Nested(t *testing.T) {
+	checkLeaks(t)
+	a, b, c := make(chan int), make(chan int), make(chan int)
+	res := Or(Or[int](a, b), c)
+	close(b)
+	if !closed(t, res) {
+		t.Error("nested Or() not closed")
+	}
+}
+
+func TestAnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		n      int
+		act    func(chs []chan int)
+		closed bool
+	}{
+		{name: "no channels", n: 0, act: func([]chan int) {}, closed: true},
+		{name: "some closed", n: 3, act: func(chs []chan int) { close(chs[0]); close(chs[2]) }, closed: false},
+		{
+			name: "all closed after values",
+			n:    3,
+			act: func(chs []chan int) {
+				for _, c := range chs {
+					c <- 1
+					close(c)
+				}
+			},
+			closed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLeaks(t)
+			chs := newChannels(tt.n)
+			res := And(recv(chs)...)
+			tt.act(chs)
+
+			if got := closed(t, res); got != tt.closed {
+				t.Errorf("And() closed = %v, want %v", got, tt.closed)
+			}
+			if !tt.closed {
+				// The helper exits once the rest are closed.
+				close(chs[1])
+				if !closed(t, res) {
+					t.Error("And() not closed after all channels were")
+				}
+			}
+		})
+	}
+}
+
+func TestOrContext(t *testing.T) {
+	tests := []struct {
+		name string
+		act  func(chs []chan int, parentCancel, cancel context.CancelFunc)
+		err  error
+	}{
+		{name: "channel closed", act: func(chs []chan int, _, _ context.CancelFunc) { close(chs[2]) }, err: context.Canceled},
+		{name: "value sent", act: func(chs []chan int, _, _ context.CancelFunc) { chs[0] <- 1 }, err: context.Canceled},
+		{name: "parent canceled", act: func(_ []chan int, parentCancel, _ context.CancelFunc) { parentCancel() }, err: context.Canceled},
+		{name: "canceled", act: func(_ []chan int, _, cancel context.CancelFunc) { cancel() }, err: context.Canceled},
+		{name: "undecided", act: func([]chan int, context.CancelFunc, context.CancelFunc) {}, err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLeaks(t)
+			parent, parentCancel := context.WithCancel(context.Background())
+			defer parentCancel()
+			chs := newChannels(3)
+			ctx, cancel := OrContext(parent, recv(chs)...)
+			defer cancel()
+
+			tt.act(chs, parentCancel, cancel)
+			if tt.err != nil {
+				select {
+				case <-ctx.Done():
+				case <-time.After(time.Second):
+					t.Fatal("context not done")
+				}
+			}
+			if ctx.Err() != tt.err {
+				t.Errorf("ctx.Err() = %v, want %v", ctx.Err(), tt.err)
+			}
+		})
+	}
+}
+
+// or2 is the sync.Once based or-channel this package started from, kept
+// as the baseline of the benchmarks. Its goroutines exit only when their
+// own channel is closed.
+func or2(channels ...<-chan int) <-chan int {
+	res := make(chan int)
+
+	var s sync.Once
+	for _, c := range channels {
+		go func() {
+			<-c
+
+			s.Do(func() {
+				close(res)
+			})
+		}()
+	}
+
+	return res
+}
+
+// BenchmarkOr waits for one of n channels to close. Besides time and
+// allocations it reports the goroutines started with their stack memory,
+// which allocations do not count, and the latency from the close to the
+// result.
+func BenchmarkOr(b *testing.B) {
+	for _, impl := range []struct {
+		name string
+		or   func(...<-chan int) <-chan int
+	}{
+		{"or2", or2},
+		{"Or", Or[int]},
+		{"OrSelect", OrSelect[int]},
+	} {
+		for _, n := range []int{10, 1000, 100000} {
+			b.Run(fmt.Sprintf("%s/%d", impl.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				var goroutines int
+				var stack uint64
+				var latency time.Duration
+				var before, after runtime.MemStats
+				for range b.N {
+					b.StopTimer()
+					chs := make([]chan int, n)
+					for i := range chs {
+						chs[i] = make(chan int)
+					}
+					base := runtime.NumGoroutine()
+					runtime.ReadMemStats(&before)
+					b.StartTimer()
+
+					res := impl.or(recv(chs)...)
+
+					b.StopTimer()
+					goroutines += runtime.NumGoroutine() - base
+					runtime.ReadMemStats(&after)
+					stack += after.StackInuse - min(before.StackInuse, after.StackInuse)
+					b.StartTimer()
+
+					start := time.Now()
+					close(chs[n/2])
+					<-res
+					latency += time.Since(start)
+
+					// or2 leaves a goroutine per open channel behind.
+					b.StopTimer()
+					for i, c := range chs {
+						if i != n/2 {
+							close(c)
+						}
+					}
+					for runtime.NumGoroutine() > base {
+						runtime.Gosched()
+					}
+					b.StartTimer()
+				}
+				b.ReportMetric(float64(goroutines)/float64(b.N), "goroutines/op")
+				b.ReportMetric(float64(stack)/float64(b.N), "stack-B/op")
+				b.ReportMetric(float64(latency.Nanoseconds())/float64(b.N), "latency-ns/op")
+			})
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package chans
+
+import (
+	"reflect"
+	"sync"
+)
+
+// selectBatch is the number of channels one goroutine of OrSelect waits
+// on. reflect.Select takes at most 65536 cases.
+const selectBatch = 1024
+
+// OrSelect is Or for many channels. Instead of a goroutine per channel it
+// waits on batches of selectBatch channels with reflect.Select, so that
+// thousands of channels take a few goroutines.
+func OrSelect[T any](channels ...<-chan T) <-chan T {
+	if len(channels) == 0 {
+		return nil
+	}
+
+	res := make(chan T)
+	var once sync.Once
+	for start := 0; start < len(channels); start += selectBatch {
+		batch := channels[start:min(start+selectBatch, len(channels))]
+
+		// The first case ends the goroutine once another batch decided.
+		cases := make([]reflect.SelectCase, 0, len(batch)+1)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf((<-chan T)(res))})
+		for _, c := range batch {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)})
+		}
+
+		go func() {
+			if chosen, _, _ := reflect.Select(cases); chosen > 0 {
+				once.Do(func() { close(res) })
+			}
+		}()
+	}
+	return res
+}
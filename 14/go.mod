@@ -0,0 +1,3 @@
+module orchannel
+
+go 1.25.5
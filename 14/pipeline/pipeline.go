@@ -0,0 +1,216 @@
+// Package pipeline builds channel pipelines around the or-channel pattern.
+// Every stage runs until its input is exhausted or its context is done,
+// then closes its outputs, so canceling the context tears down a whole
+// pipeline without leaking goroutines. Values still in flight at
+// cancellation are dropped.
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OrDone passes on the values of in until it is closed or ctx is done, so
+// that ranging over a channel can be given up.
+func OrDone[T any](ctx context.Context, in <-chan T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// send sends v on out unless ctx is done first, and reports whether it did.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Tee sends every value of in to both outputs. The next value is read once
+// both have taken the current one, so the slower reader sets the pace.
+func Tee[T any](ctx context.Context, in <-chan T) (<-chan T, <-chan T) {
+	out1, out2 := make(chan T), make(chan T)
+	go func() {
+		defer close(out1)
+		defer close(out2)
+		for v := range OrDone(ctx, in) {
+			// A nil channel blocks, so each output is sent to once.
+			o1, o2 := out1, out2
+			for range 2 {
+				select {
+				case <-ctx.Done():
+					return
+				case o1 <- v:
+					o1 = nil
+				case o2 <- v:
+					o2 = nil
+				}
+			}
+		}
+	}()
+	return out1, out2
+}
+
+// FanIn merges the values of all inputs into one channel, which is closed
+// once all inputs are.
+func FanIn[T any](ctx context.Context, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	for _, in := range ins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range OrDone(ctx, in) {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// FanOut distributes the values of in over n outputs. Each value goes to
+// one output, whichever is ready to take it first.
+func FanOut[T any](ctx context.Context, in <-chan T, n int) []<-chan T {
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		go func() {
+			defer close(out)
+			for v := range OrDone(ctx, in) {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}()
+	}
+	return outs
+}
+
+// Bridge flattens a channel of channels into one channel, reading the
+// inner channels one after another.
+func Bridge[T any](ctx context.Context, chans <-chan (<-chan T)) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for in := range OrDone(ctx, chans) {
+			for v := range OrDone(ctx, in) {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Take passes on the first n values of in.
+func Take[T any](ctx context.Context, in <-chan T, n int) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for i := 0; i < n; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case v, ok := <-in:
+				if !ok || !send(ctx, out, v) {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Repeat sends values over and over until ctx is done. With no values the
+// channel is closed at once.
+func Repeat[T any](ctx context.Context, values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		if len(values) == 0 {
+			return
+		}
+		for {
+			for _, v := range values {
+				if !send(ctx, out, v) {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// RateLimit passes on the values of in at most one per interval on
+// average, letting bursts of up to burst values through after idle time.
+// An interval of zero or less means no limit, and a burst below one is
+// taken as one.
+func RateLimit[T any](ctx context.Context, in <-chan T, interval time.Duration, burst int) <-chan T {
+	if interval <= 0 {
+		return OrDone(ctx, in)
+	}
+
+	out := make(chan T)
+	tokens := make(chan struct{}, max(burst, 1))
+	for range cap(tokens) {
+		tokens <- struct{}{}
+	}
+
+	// done stops the refill once the output is closed.
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-t.C:
+				select {
+				case tokens <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	go func() {
+		defer close(out)
+		defer close(done)
+		for v := range OrDone(ctx, in) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tokens:
+			}
+			if !send(ctx, out, v) {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,229 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkLeaks fails the test if goroutines started during it are still
+// running once it is over.
+func checkLeaks(t *testing.T) {
+	t.Helper()
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > before {
+			if time.Now().After(deadline) {
+				t.Errorf("%d goroutines leaked", runtime.NumGoroutine()-before)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+// generate sends values and closes the channel.
+func generate[T any](values ...T) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		for _, v := range values {
+			out <- v
+		}
+	}()
+	return out
+}
+
+func collect[T any](in <-chan T) []T {
+	var res []T
+	for v := range in {
+		res = append(res, v)
+	}
+	return res
+}
+
+func sorted(values []int) []int {
+	slices.Sort(values)
+	return values
+}
+
+func TestStages(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context) []int
+		want []int
+	}{
+		{
+			name: "OrDone",
+			run:  func(ctx context.Context) []int { return collect(OrDone(ctx, generate(1, 2, 3))) },
+			want: []int{1, 2, 3},
+		},
+		{
+			name: "FanIn",
+			run: func(ctx context.Context) []int {
+				return sorted(collect(FanIn(ctx, generate(1, 4), generate(2), generate[int]())))
+			},
+			want: []int{1, 2, 4},
+		},
+		{
+			name: "FanIn of nothing",
+			run:  func(ctx context.Context) []int { return collect(FanIn[int](ctx)) },
+			want: nil,
+		},
+		{
+			name: "FanOut",
+			run: func(ctx context.Context) []int {
+				outs := FanOut(ctx, generate(1, 2, 3, 4, 5), 3)
+				return sorted(collect(FanIn(ctx, outs...)))
+			},
+			want: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name: "Bridge",
+			run: func(ctx context.Context) []int {
+				return collect(Bridge(ctx, generate(generate(1, 2), generate[int](), generate(3))))
+			},
+			want: []int{1, 2, 3},
+		},
+		{
+			name: "Take of Repeat",
+			run:  func(ctx context.Context) []int { return collect(Take(ctx, Repeat(ctx, 1, 2), 5)) },
+			want: []int{1, 2, 1, 2, 1},
+		},
+		{
+			name: "Take more than there is",
+			run:  func(ctx context.Context) []int { return collect(Take(ctx, generate(1, 2), 5)) },
+			want: []int{1, 2},
+		},
+		{
+			name: "Repeat nothing",
+			run:  func(ctx context.Context) []int { return collect(Repeat[int](ctx)) },
+			want: nil,
+		},
+		{
+			name: "RateLimit",
+			run: func(ctx context.Context) []int {
+				return collect(RateLimit(ctx, generate(1, 2, 3), time.Millisecond, 1))
+			},
+			want: []int{1, 2, 3},
+		},
+		{
+			name: "RateLimit without interval",
+			run: func(ctx context.Context) []int {
+				return collect(RateLimit(ctx, generate(1, 2, 3), 0, 0))
+			},
+			want: []int{1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLeaks(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if got := tt.run(ctx); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTee(t *testing.T) {
+	checkLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out1, out2 := Tee(ctx, generate(1, 2, 3))
+	var got1, got2 []int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		got1 = collect(out1)
+	}()
+	go func() {
+		defer wg.Done()
+		got2 = collect(out2)
+	}()
+	wg.Wait()
+
+	want := []int{1, 2, 3}
+	if !slices.Equal(got1, want) || !slices.Equal(got2, want) {
+		t.Errorf("Tee() = %v, %v, want %v twice", got1, got2, want)
+	}
+}
+
+// TestCancel cancels pipelines whose readers stopped reading halfway and
+// whose sources never end: all stages must close their outputs and exit.
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(ctx context.Context) []<-chan int
+	}{
+		{"OrDone", func(ctx context.Context) []<-chan int { return []<-chan int{OrDone(ctx, Repeat(ctx, 1))} }},
+		{"Tee", func(ctx context.Context) []<-chan int {
+			a, b := Tee(ctx, Repeat(ctx, 1))
+			return []<-chan int{a, b}
+		}},
+		{"FanIn", func(ctx context.Context) []<-chan int {
+			return []<-chan int{FanIn(ctx, Repeat(ctx, 1), make(chan int))}
+		}},
+		{"FanOut", func(ctx context.Context) []<-chan int { return FanOut(ctx, Repeat(ctx, 1), 3) }},
+		{"Bridge", func(ctx context.Context) []<-chan int {
+			return []<-chan int{Bridge(ctx, Repeat(ctx, Repeat(ctx, 1)))}
+		}},
+		{"Take", func(ctx context.Context) []<-chan int { return []<-chan int{Take(ctx, make(chan int), 3)} }},
+		{"RateLimit", func(ctx context.Context) []<-chan int {
+			return []<-chan int{RateLimit(ctx, Repeat(ctx, 1), time.Hour, 2)}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLeaks(t)
+			ctx, cancel := context.WithCancel(context.Background())
+			outs := tt.build(ctx)
+
+			// Read a little, then stop reading.
+			select {
+			case <-outs[0]:
+			case <-time.After(10 * time.Millisecond):
+			}
+			cancel()
+
+			for i, out := range outs {
+				timeout := time.After(time.Second)
+				for open := true; open; {
+					select {
+					case _, open = <-out:
+					case <-timeout:
+						t.Fatalf("output %d not closed after cancel", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	checkLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const interval = 20 * time.Millisecond
+	start := time.Now()
+	got := collect(RateLimit(ctx, generate(1, 2, 3, 4, 5), interval, 2))
+
+	// Two values pass at once, the other three wait for a tick each.
+	if elapsed := time.Since(start); elapsed < 3*interval {
+		t.Errorf("5 values with a burst of 2 took %v, want at least %v", elapsed, 3*interval)
+	}
+	if !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("RateLimit() = %v", got)
+	}
+}
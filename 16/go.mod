@@ -2,4 +2,4 @@ module wb-wget
 
 go 1.25.5
 
-require golang.org/x/net v0.49.0 // indirect
+require golang.org/x/net v0.49.0
@@ -2,8 +2,9 @@ module ntp
 
 go 1.24.6
 
+require github.com/beevik/ntp v1.5.0
+
 require (
-	github.com/beevik/ntp v1.5.0 // indirect
 	golang.org/x/net v0.44.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 )